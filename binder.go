@@ -0,0 +1,394 @@
+package fastapi
+
+import (
+	"encoding"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fieldScalar uint8 = iota
+	fieldSlice
+	fieldFile
+	fieldFiles
+	fieldStruct
+	fieldStructPtr
+)
+
+// binders caches a *structBinder per struct type
+var binders sync.Map
+
+// structBinder is the reflection metadata Bind needs for a struct type,
+// compiled once per type.
+type structBinder struct {
+	fields []*fieldBinder
+	err    error
+}
+
+type fieldBinder struct {
+	index  int
+	name   string
+	kind   uint8
+	typ    reflect.Type
+	names  map[string]string
+	set    func(v reflect.Value, s string) error
+	nested *structBinder
+
+	// cycle marks a struct pointer back to an enclosing type, it is never allocated
+	cycle bool
+
+	hasDefault bool
+	defaults   reflect.Value
+}
+
+func getBinder(typ reflect.Type) (*structBinder, error) {
+	if b, ok := binders.Load(typ); ok {
+		return b.(*structBinder), b.(*structBinder).err
+	}
+	var b = compileBinder(typ, make(map[reflect.Type]*structBinder))
+	binders.Store(typ, b)
+	return b, b.err
+}
+
+func compileBinder(typ reflect.Type, building map[reflect.Type]*structBinder) *structBinder {
+	var b = &structBinder{fields: make([]*fieldBinder, 0, typ.NumField())}
+	building[typ] = b
+	defer delete(building, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		t := typ.Field(i)
+		if t.Name[0] >= 'a' && t.Name[0] <= 'z' {
+			continue
+		}
+
+		var f = &fieldBinder{index: i, name: t.Name, typ: t.Type, names: make(map[string]string)}
+		var kind = t.Type.Kind()
+		switch {
+		case t.Type == fileHeaderType:
+			f.kind = fieldFile
+		case t.Type == fileHeadersType:
+			f.kind = fieldFiles
+		case kind == reflect.Struct && !isScalar(t.Type):
+			f.kind = fieldStruct
+			f.nested = compileBinder(t.Type, building)
+		case kind == reflect.Ptr && !isScalar(t.Type):
+			if t.Type.Elem().Kind() != reflect.Struct {
+				continue
+			}
+			f.kind = fieldStructPtr
+			if _, ok := building[t.Type.Elem()]; ok {
+				f.cycle = true
+			} else {
+				f.nested = compileBinder(t.Type.Elem(), building)
+			}
+		case kind == reflect.Slice && !isScalar(t.Type):
+			if !isScalar(t.Type.Elem()) {
+				continue
+			}
+			f.kind = fieldSlice
+			f.set = newSetter(t.Type.Elem())
+		case isScalar(t.Type):
+			f.kind = fieldScalar
+			f.set = newSetter(t.Type)
+		default:
+			// maps, interfaces, arrays, chans and funcs are not bound
+			continue
+		}
+		if f.nested != nil && f.nested.err != nil {
+			b.err = f.nested.err
+		}
+
+		// named like the validator and the OpenAPI document name it, json:"-"
+		// fields are only read from their path, header, cookie or query tag
+		if tag := fieldName(t); tag != "" {
+			if f.kind == fieldSlice || f.kind == fieldFiles {
+				tag += "[]"
+			}
+			f.names[""] = tag
+		}
+		for _, source := range []string{"path", "header", "cookie", "query"} {
+			if name := t.Tag.Get(source); name != "" {
+				if source == "header" {
+					name = textproto.CanonicalMIMEHeaderKey(name)
+				}
+				f.names[source] = name
+			}
+		}
+
+		if val := t.Tag.Get("default"); val != "" && (f.kind == fieldScalar || f.kind == fieldSlice) {
+			if err := f.compileDefault(val); err != nil {
+				b.err = &TransError{Field: t.Name, Message: fmt.Sprintf("invalid default value %q for %s", val, t.Name)}
+			}
+		}
+		b.fields = append(b.fields, f)
+	}
+	return b
+}
+
+// compileDefault converts the default tag once. Slice defaults are comma separated.
+func (f *fieldBinder) compileDefault(val string) error {
+	var typ = f.typ
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	var v = reflect.New(typ).Elem()
+	if f.kind == fieldSlice {
+		if err := f.setSlice(v, strings.Split(val, ",")); err != nil {
+			return err
+		}
+	} else if err := newSetter(typ)(v, val); err != nil {
+		return err
+	}
+	f.hasDefault = true
+	f.defaults = v
+	return nil
+}
+
+func (f *fieldBinder) setSlice(v reflect.Value, vals []string) error {
+	var arr = reflect.MakeSlice(v.Type(), len(vals), len(vals))
+	for i := range vals {
+		if err := f.set(arr.Index(i), vals[i]); err != nil {
+			return err
+		}
+	}
+	v.Set(arr)
+	return nil
+}
+
+// bindValues sets the fields named by the source tag from query. An empty source
+// names fields by their json tag or field name, and reads slices from name[].
+// A value that does not convert to the field type is reported as a *TransError.
+// Nil struct pointers are allocated only when one of their fields is present;
+// found reports whether any field was set.
+func (b *structBinder) bindValues(query url.Values, source string, values reflect.Value) (found bool, err error) {
+	for _, f := range b.fields {
+		v := values.Field(f.index)
+		if _, ok := f.names[source]; !ok && source == "" {
+			continue
+		}
+
+		switch f.kind {
+		case fieldFile, fieldFiles:
+			continue
+		case fieldStruct:
+			ok, err := f.nested.bindValues(query, source, v)
+			if err != nil {
+				return false, err
+			}
+			found = found || ok
+			continue
+		case fieldStructPtr:
+			if f.cycle {
+				continue
+			}
+			var item = v
+			if v.IsNil() {
+				item = reflect.New(f.typ.Elem())
+			}
+			ok, err := f.nested.bindValues(query, source, item.Elem())
+			if err != nil {
+				return false, err
+			}
+			if ok && v.IsNil() {
+				v.Set(item)
+			}
+			found = found || ok
+			continue
+		}
+
+		var name, ok = f.names[source]
+		if !ok {
+			continue
+		}
+		vals, ok := query[name]
+		if !ok && source != "" && f.kind == fieldSlice {
+			vals, ok = query[name+"[]"]
+		}
+		if !ok || len(vals) == 0 {
+			continue
+		}
+
+		if f.kind == fieldSlice {
+			err = f.setSlice(v, vals)
+		} else {
+			err = f.set(v, vals[0])
+		}
+		if err != nil {
+			name = strings.TrimSuffix(name, "[]")
+			return false, &TransError{Field: name, Message: fmt.Sprintf("invalid value for %s", name)}
+		}
+		found = true
+	}
+	return found, nil
+}
+
+// bindFiles sets *multipart.FileHeader and []*multipart.FileHeader fields,
+// named like bindForm names them, and reports whether any was set
+func (b *structBinder) bindFiles(files map[string][]*multipart.FileHeader, values reflect.Value) (found bool) {
+	for _, f := range b.fields {
+		v := values.Field(f.index)
+		if _, ok := f.names[""]; !ok {
+			continue
+		}
+
+		switch f.kind {
+		case fieldStruct:
+			found = f.nested.bindFiles(files, v) || found
+		case fieldStructPtr:
+			if f.cycle {
+				continue
+			}
+			var item = v
+			if v.IsNil() {
+				item = reflect.New(f.typ.Elem())
+			}
+			if f.nested.bindFiles(files, item.Elem()) {
+				if v.IsNil() {
+					v.Set(item)
+				}
+				found = true
+			}
+		case fieldFile, fieldFiles:
+			var name = strings.TrimSuffix(f.names[""], "[]")
+			fhs, ok := files[name]
+			if !ok {
+				fhs = files[name+"[]"]
+			}
+			if len(fhs) == 0 {
+				continue
+			}
+			if f.kind == fieldFile {
+				v.Set(reflect.ValueOf(fhs[0]))
+			} else {
+				v.Set(reflect.ValueOf(fhs))
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// setDefault fills the zero fields with their default tag.
+// Nil struct pointers are left nil, they were absent from the request.
+func (b *structBinder) setDefault(values reflect.Value) {
+	for _, f := range b.fields {
+		v := values.Field(f.index)
+
+		switch f.kind {
+		case fieldStruct:
+			f.nested.setDefault(v)
+		case fieldStructPtr:
+			if !f.cycle && !v.IsNil() {
+				f.nested.setDefault(v.Elem())
+			}
+		case fieldScalar, fieldSlice:
+			if !f.hasDefault || !v.IsZero() {
+				continue
+			}
+			if f.typ.Kind() == reflect.Ptr {
+				var p = reflect.New(f.typ.Elem())
+				p.Elem().Set(f.defaults)
+				v.Set(p)
+			} else if f.kind == fieldSlice {
+				var arr = reflect.MakeSlice(f.typ, f.defaults.Len(), f.defaults.Len())
+				reflect.Copy(arr, f.defaults)
+				v.Set(arr)
+			} else {
+				v.Set(f.defaults)
+			}
+		}
+	}
+}
+
+// newSetter returns a func converting a string to typ, allocating nil pointers
+func newSetter(typ reflect.Type) func(v reflect.Value, s string) error {
+	if typ.Kind() == reflect.Ptr {
+		var set = newSetter(typ.Elem())
+		return func(v reflect.Value, s string) error {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			return set(v.Elem(), s)
+		}
+	}
+
+	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return func(v reflect.Value, s string) error {
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		}
+	}
+
+	if typ == durationType {
+		return func(v reflect.Value, s string) error {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+	}
+
+	var bits = 0
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		bits = typ.Bits()
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return func(v reflect.Value, s string) error {
+			v.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		return func(v reflect.Value, s string) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value, s string) error {
+			n, err := strconv.ParseInt(s, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value, s string) error {
+			n, err := strconv.ParseUint(s, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetUint(n)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value, s string) error {
+			f, err := strconv.ParseFloat(s, bits)
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+			return nil
+		}
+	}
+
+	return func(v reflect.Value, s string) error {
+		return fmt.Errorf("unsupported type %s", typ.String())
+	}
+}
@@ -0,0 +1,249 @@
+package fastapi
+
+import (
+	"mime/multipart"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// bindQuery binds v from a GET request to target
+func bindQuery(v interface{}, target string) error {
+	var ctx = newContext(httptest.NewRequest("GET", target, nil), httptest.NewRecorder())
+	return ctx.Bind(v)
+}
+
+type conversionForm struct {
+	Int8     int8          `json:"int8"`
+	Int64    int64         `json:"int64"`
+	Uint16   uint16        `json:"uint16"`
+	Float32  float32       `json:"float32"`
+	Bool     bool          `json:"bool"`
+	Duration time.Duration `json:"duration"`
+	Time     time.Time     `json:"time"`
+	Ptr      *int          `json:"ptr"`
+	Ints     []int         `json:"ints"`
+	Ptrs     []*string     `json:"ptrs"`
+}
+
+func TestBindConversions(t *testing.T) {
+	var form conversionForm
+	var err = bindQuery(&form, "/?int8=-8&int64=64&uint16=16&float32=1.5&bool=true&duration=1m"+
+		"&time=2020-01-02T03:04:05Z&ptr=7&ints[]=1&ints[]=2&ptrs[]=a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Int8 != -8 || form.Int64 != 64 || form.Uint16 != 16 || form.Float32 != 1.5 || !form.Bool ||
+		form.Duration != time.Minute || form.Time.Year() != 2020 || form.Ptr == nil || *form.Ptr != 7 ||
+		len(form.Ints) != 2 || form.Ints[1] != 2 || len(form.Ptrs) != 1 || *form.Ptrs[0] != "a" {
+		t.Errorf("%+v", form)
+	}
+
+	for query, field := range map[string]string{
+		"/?int8=300":          "int8",
+		"/?uint16=-1":         "uint16",
+		"/?bool=maybe":        "bool",
+		"/?duration=1":        "duration",
+		"/?time=yesterday":    "time",
+		"/?ints[]=1&ints[]=x": "ints",
+	} {
+		err, ok := bindQuery(&conversionForm{}, query).(*TransError)
+		if !ok || err.Field != field || err.Message != "invalid value for "+field {
+			t.Errorf("%s: %v", query, err)
+		}
+	}
+}
+
+type defaultForm struct {
+	Page  int           `json:"page" default:"1"`
+	Size  *int          `json:"size" default:"20"`
+	Sort  string        `json:"sort" default:"asc"`
+	Tags  []string      `json:"tags" default:"a,b"`
+	Every time.Duration `json:"every" default:"1h"`
+}
+
+func TestBindDefaults(t *testing.T) {
+	var form defaultForm
+	if err := bindQuery(&form, "/?sort=desc"); err != nil {
+		t.Fatal(err)
+	}
+	if form.Page != 1 || form.Size == nil || *form.Size != 20 || form.Sort != "desc" ||
+		len(form.Tags) != 2 || form.Tags[1] != "b" || form.Every != time.Hour {
+		t.Errorf("%+v", form)
+	}
+
+	// every bind gets its own copy of slice defaults
+	form.Tags[0] = "changed"
+	var other defaultForm
+	bindQuery(&other, "/")
+	if other.Tags[0] != "a" {
+		t.Errorf("the default slice is shared: %v", other.Tags)
+	}
+
+	var invalid struct {
+		Page int `json:"page" default:"one"`
+	}
+	if err := bindQuery(&invalid, "/"); err == nil {
+		t.Error("an invalid default is accepted")
+	}
+}
+
+type pointerForm struct {
+	Name    string       `json:"name"`
+	Address *addressForm `json:"address"`
+	Next    *pointerForm `json:"next"`
+}
+
+type addressForm struct {
+	City string `json:"city"`
+	Zip  string `json:"zip" default:"00000"`
+}
+
+func TestBindStructPointers(t *testing.T) {
+	var form pointerForm
+	if err := bindQuery(&form, "/?name=x"); err != nil {
+		t.Fatal(err)
+	}
+	if form.Address != nil || form.Next != nil {
+		t.Errorf("absent pointers allocated: %+v", form)
+	}
+
+	form = pointerForm{}
+	if err := bindQuery(&form, "/?city=paris"); err != nil {
+		t.Fatal(err)
+	}
+	if form.Address == nil || form.Address.City != "paris" || form.Address.Zip != "00000" {
+		t.Errorf("address %+v", form.Address)
+	}
+	if form.Next != nil {
+		t.Error("a recursive pointer is allocated")
+	}
+}
+
+type fileNode struct {
+	File *multipart.FileHeader `json:"file"`
+	Next *fileNode             `json:"next"`
+}
+
+func TestBindStructPointerFiles(t *testing.T) {
+	var s = New()
+	s.POST("/upload", func(ctx *Context) {
+		var form struct {
+			Node  *fileNode `json:"node"`
+			Empty *struct {
+				Missing *multipart.FileHeader `json:"missing"`
+			} `json:"empty"`
+		}
+		if err := ctx.Bind(&form); err != nil {
+			t.Fatal(err)
+		}
+		if form.Node == nil || form.Node.File.Filename != "a.txt" || form.Node.Next != nil || form.Empty != nil {
+			t.Errorf("%+v", form)
+		}
+		ctx.Write(200, nil)
+	})
+	body, contentType := multipartBody(t, nil, "file", "a.txt", "a")
+	if w := request(s, "POST", "/upload", body, "Content-Type", contentType); w.Code != 200 {
+		t.Errorf("%d %s", w.Code, w.Body.String())
+	}
+}
+
+type unsupportedForm struct {
+	Name    string            `json:"name"`
+	Labels  map[string]string `json:"labels" default:"x"`
+	Any     interface{}       `json:"any"`
+	Matrix  [][]int           `json:"matrix"`
+	Pair    [2]int            `json:"pair"`
+	Done    chan bool         `json:"done"`
+	private int
+}
+
+func TestBindUnsupportedKinds(t *testing.T) {
+	var form unsupportedForm
+	if err := bindQuery(&form, "/?name=x&labels=a&any=b&matrix[]=1&pair[]=1&done=true"); err != nil {
+		t.Fatal(err)
+	}
+	if form.Name != "x" || form.Labels != nil || form.Any != nil || form.Matrix != nil || form.Pair != [2]int{} {
+		t.Errorf("%+v", form)
+	}
+
+	b, _ := getBinder(reflect.TypeOf(form))
+	if len(b.fields) != 1 || b.fields[0].name != "Name" {
+		t.Errorf("%d fields compiled", len(b.fields))
+	}
+}
+
+type taggedForm struct {
+	Page   int    `json:"page,omitempty"`
+	ID     int    `json:"-" query:"id"`
+	Secret string `json:"-"`
+}
+
+func TestBindJSONTagOptions(t *testing.T) {
+	var form taggedForm
+	if err := bindQuery(&form, "/?page=2&-=1&id=3"); err != nil {
+		t.Fatal(err)
+	}
+	if form.Page != 2 || form.ID != 3 || form.Secret != "" {
+		t.Errorf("%+v", form)
+	}
+
+	form = taggedForm{}
+	if err := bindQuery(&form, "/?-=x"); err != nil {
+		t.Fatal(err)
+	}
+	if form.ID != 0 || form.Secret != "" {
+		t.Errorf("json:\"-\" fields bound from \"-\": %+v", form)
+	}
+}
+
+func TestGetBinderCache(t *testing.T) {
+	var typ = reflect.TypeOf(pointerForm{})
+	b1, _ := getBinder(typ)
+	b2, _ := getBinder(typ)
+	if b1 != b2 {
+		t.Error("the binder is compiled twice")
+	}
+	var next = b1.fields[2]
+	if next.name != "Next" || !next.cycle || next.nested != nil {
+		t.Errorf("Next field %+v", next)
+	}
+}
+
+type benchForm struct {
+	Name    string       `json:"name" validate:"required"`
+	Age     int          `json:"age" default:"18"`
+	Tags    []string     `json:"tags"`
+	Page    int          `query:"page"`
+	Tenant  string       `header:"x-tenant"`
+	Address *addressForm `json:"address"`
+}
+
+// BenchmarkBind compares the cached binder with compiling the binder on every
+// request, which costs the reflection walk Bind used to do each time
+func BenchmarkBind(b *testing.B) {
+	var req = httptest.NewRequest("GET", "/?name=x&tags[]=a&tags[]=b&page=2&city=paris", nil)
+	req.Header.Set("X-Tenant", "acme")
+	var typ = reflect.TypeOf(benchForm{})
+
+	for _, cached := range []bool{true, false} {
+		var name = "cached"
+		if !cached {
+			name = "reflection"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if !cached {
+					binders.Delete(typ)
+				}
+				var form benchForm
+				var ctx = newContext(req, httptest.NewRecorder())
+				if err := ctx.Bind(&form); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package fastapi
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // A Code is an unsigned 32-bit error code as defined in the gRPC spec.
@@ -148,6 +150,102 @@ var strToCode = map[string]Code{
 	`"UNAUTHENTICATED"`:     Unauthenticated,
 }
 
+var codeToStr = make(map[Code]string, _maxCode)
+
+func init() {
+	for k, v := range strToCode {
+		codeToStr[v] = strings.Trim(k, `"`)
+	}
+}
+
+var codeToStatus = map[Code]int{
+	OK:                 200,
+	Canceled:           499,
+	Unknown:            500,
+	InvalidArgument:    400,
+	DeadlineExceeded:   504,
+	NotFound:           404,
+	AlreadyExists:      409,
+	PermissionDenied:   403,
+	ResourceExhausted:  429,
+	FailedPrecondition: 400,
+	Aborted:            409,
+	OutOfRange:         400,
+	Unimplemented:      501,
+	Internal:           500,
+	Unavailable:        503,
+	DataLoss:           500,
+	Unauthenticated:    401,
+}
+
+// HTTPStatus returns the http status of the code, as gRPC gateways map it.
+// 499 is the nginx status for a request canceled by the client, unknown codes are 500.
+func (c Code) HTTPStatus() int {
+	if status, ok := codeToStatus[c]; ok {
+		return status
+	}
+	return 500
+}
+
+// RegisterCode adds an application code above the gRPC ones, named like them,
+// e.g. RegisterCode(1001, "QUOTA_FROZEN", 403). status is its http status.
+// Call it before serving, the code tables are not safe for concurrent writes.
+func RegisterCode(code Code, name string, status int) {
+	if code < _maxCode {
+		panic(fmt.Sprintf("fastapi: code %d is reserved for gRPC codes", code))
+	}
+	if _, ok := codeToStr[code]; ok {
+		panic(fmt.Sprintf("fastapi: code %d is already registered", code))
+	}
+	if _, ok := strToCode[strconv.Quote(name)]; ok {
+		panic(fmt.Sprintf("fastapi: code name %s is already registered", name))
+	}
+	strToCode[strconv.Quote(name)] = code
+	codeToStr[code] = name
+	codeToStatus[code] = status
+}
+
+// sortedCodes returns the gRPC and registered codes in order
+func sortedCodes() []Code {
+	var codes = make([]Code, 0, len(codeToStr))
+	for code := range codeToStr {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i] < codes[j]
+	})
+	return codes
+}
+
+// String returns the name of the code, e.g. NOT_FOUND, or Code(n) when it is unknown
+func (c Code) String() string {
+	if name, ok := codeToStr[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.FormatInt(int64(c), 10) + ")"
+}
+
+// MarshalJSON marshals the code as a number, see Server.SetCodeEncoding for names
+func (c Code) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatUint(uint64(c), 10)), nil
+}
+
+// MarshalText marshals the code as its name, e.g. for map keys and logs
+func (c Code) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText accepts a name, e.g. NOT_FOUND, or a number
+func (c *Code) UnmarshalText(b []byte) error {
+	if c == nil {
+		return fmt.Errorf("nil receiver passed to UnmarshalText")
+	}
+	if _, err := strconv.ParseUint(string(b), 10, 32); err == nil {
+		return c.UnmarshalJSON(b)
+	}
+	return c.UnmarshalJSON([]byte(strconv.Quote(string(b))))
+}
+
 // UnmarshalJSON unmarshals b into the Code.
 func (c *Code) UnmarshalJSON(b []byte) error {
 	// From json.Unmarshaler: By convention, to approximate the behavior of
@@ -161,8 +259,8 @@ func (c *Code) UnmarshalJSON(b []byte) error {
 	}
 
 	if ci, err := strconv.ParseUint(string(b), 10, 32); err == nil {
-		if ci >= _maxCode {
-			return fmt.Errorf("invalid code: %q", ci)
+		if _, ok := codeToStr[Code(ci)]; !ok {
+			return fmt.Errorf("invalid code: %d", ci)
 		}
 
 		*c = Code(ci)
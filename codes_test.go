@@ -0,0 +1,105 @@
+package fastapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCodeHTTPStatus(t *testing.T) {
+	for code, want := range map[Code]int{
+		OK:                200,
+		Canceled:          499,
+		InvalidArgument:   400,
+		NotFound:          404,
+		PermissionDenied:  403,
+		ResourceExhausted: 429,
+		Unimplemented:     501,
+		Unauthenticated:   401,
+		Code(999):         500,
+	} {
+		if status := code.HTTPStatus(); status != want {
+			t.Errorf("%d: %d, want %d", code, status, want)
+		}
+	}
+}
+
+func TestCodeString(t *testing.T) {
+	for code, want := range map[Code]string{
+		OK:              "OK",
+		Canceled:        "CANCELLED",
+		NotFound:        "NOT_FOUND",
+		Unauthenticated: "UNAUTHENTICATED",
+		Code(998):       "Code(998)",
+	} {
+		if s := code.String(); s != want {
+			t.Errorf("%d: %s, want %s", code, s, want)
+		}
+	}
+}
+
+func TestCodeCodecs(t *testing.T) {
+	var holder struct {
+		Code  Code          `json:"code"`
+		Codes map[Code]bool `json:"codes"`
+	}
+	if err := json.Unmarshal([]byte(`{"code":"NOT_FOUND","codes":{"INTERNAL":true,"3":true}}`), &holder); err != nil {
+		t.Fatal(err)
+	}
+	if holder.Code != NotFound || !holder.Codes[Internal] || !holder.Codes[InvalidArgument] {
+		t.Errorf("%+v", holder)
+	}
+
+	holder.Codes = map[Code]bool{Internal: true}
+	if b, _ := json.Marshal(&holder); string(b) != `{"code":5,"codes":{"INTERNAL":true}}` {
+		t.Errorf("marshal %s", b)
+	}
+
+	var c Code
+	for _, input := range []string{`"NOPE"`, `999`, `-1`} {
+		if err := c.UnmarshalJSON([]byte(input)); err == nil {
+			t.Errorf("%s: accepted as %v", input, c)
+		}
+	}
+	if err := c.UnmarshalJSON([]byte("null")); err != nil || c != OK {
+		t.Errorf("null: %v %v", c, err)
+	}
+}
+
+func TestRegisterCode(t *testing.T) {
+	const quotaFrozen Code = 1001
+	RegisterCode(quotaFrozen, "QUOTA_FROZEN", 403)
+	defer func() {
+		delete(strToCode, `"QUOTA_FROZEN"`)
+		delete(codeToStr, quotaFrozen)
+		delete(codeToStatus, quotaFrozen)
+	}()
+	if quotaFrozen.String() != "QUOTA_FROZEN" || quotaFrozen.HTTPStatus() != 403 {
+		t.Errorf("%s %d", quotaFrozen, quotaFrozen.HTTPStatus())
+	}
+	var c Code
+	if err := c.UnmarshalText([]byte("QUOTA_FROZEN")); err != nil || c != quotaFrozen {
+		t.Errorf("UnmarshalText: %v %v", c, err)
+	}
+	if codes := sortedCodes(); codes[len(codes)-1] != quotaFrozen || codes[0] != OK {
+		t.Errorf("sortedCodes %v", codes)
+	}
+
+	for _, item := range []struct {
+		code Code
+		name string
+	}{
+		{NotFound, "MISSING"},
+		{quotaFrozen, "QUOTA_FROZEN_AGAIN"},
+		{1002, "QUOTA_FROZEN"},
+		{1003, "NOT_FOUND"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterCode(%d, %s): want a panic", item.code, item.name)
+				}
+			}()
+			RegisterCode(item.code, item.name, 400)
+		}()
+	}
+}
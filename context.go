@@ -1,59 +1,174 @@
 package fastapi
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/json-iterator/go"
 	"github.com/pkg/errors"
+	"io"
+	"math"
+	"mime"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"reflect"
 	"runtime"
+	"sync/atomic"
+	"time"
 )
 
 var defaultCatcher = func(ctx *Context, err interface{}) {
-	if err1, ok := err.(*Error); ok {
-		ctx.JSON(400, err1)
-		return
+	// errors are unwrapped, so a wrapped *Error keeps its code
+	if v, ok := err.(error); ok {
+		var err1 *Error
+		if errors.As(v, &err1) {
+			if globalMode == DebugMode {
+				logger.Error().Msgf("%+v", v)
+			}
+			ctx.renderError(ctx.httpStatus(err1), err1, nil)
+			return
+		}
+		var err2 *TransError
+		if errors.As(v, &err2) {
+			var e = NewError(InvalidArgument, err2.Message)
+			ctx.renderError(ctx.httpStatus(e), e, err2.errs)
+			return
+		}
+		var errs ValidationErrors
+		if errors.As(v, &errs) {
+			var e = NewError(InvalidArgument, errs.First().Message)
+			ctx.renderError(ctx.httpStatus(e), e, errs)
+			return
+		}
 	}
 
 	if globalMode == DebugMode {
 		buf := make([]byte, 2048)
 		n := runtime.Stack(buf, false)
 		stackInfo := fmt.Sprintf("%s", buf[:n])
-		logger.Error().Msg("Runtime Error")
+		logger.Error().Msgf("Runtime Error: %v", err)
 		println(stackInfo)
 	}
 
-	ctx.JSON(500, NewError(Internal, "internal error"))
+	var e = NewError(Internal, "internal error")
+	ctx.renderError(ctx.httpStatus(e), e, nil)
+}
+
+var defaultNoRoute = func(ctx *Context) {
+	ctx.renderError(404, NewError(NotFound, "handler not exist"), nil)
+}
+
+var defaultNoMethod = func(ctx *Context) {
+	ctx.renderError(405, NewError(Unimplemented, "method not allowed"), nil)
 }
 
 type HandlerFunc func(ctx *Context)
 
+// ErrorHandler is a link of the error chain of Server.OnError.
+// It returns the error for the next link, or nil when the error is handled.
+type ErrorHandler func(ctx *Context, err error) error
+
+// HandlerE adapts a handler returning an error. A non-nil error is reported
+// with ctx.Error and aborts the chain.
+func HandlerE(fn func(ctx *Context) error) HandlerFunc {
+	var name = runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	return func(ctx *Context) {
+		if err := fn(ctx); err != nil {
+			ctx.addError(err, name)
+			ctx.Abort()
+		}
+	}
+}
+
+// abortIndex is past the end of any chain, small enough that Next can still
+// increment it on 32-bit platforms
+const abortIndex = math.MaxInt8 / 2
+
+var (
+	fileHeaderType  = reflect.TypeOf(&multipart.FileHeader{})
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader{})
+)
+
 func newContext(req *http.Request, res http.ResponseWriter) *Context {
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	var writer = &responseWriter{ResponseWriter: res}
 	return &Context{
-		next:     true,
-		Request:  req,
-		Response: res,
-		Storage:  Any{},
+		index:       -1,
+		writer:      writer,
+		Request:     req,
+		Response:    writer,
+		Storage:     Any{},
+		ContentType: contentType,
 	}
 }
 
 type Context struct {
-	next        bool
+	index       int
+	server      *Server
+	handlers    []HandlerFunc
+	params      Params
+	stream      bool
+	bodyLoaded  bool
+	bodyErr     error
+	lang        Lang
+	langLoaded  bool
+	errors      []handlerError
+	writer      *responseWriter
 	Request     *http.Request
 	Response    http.ResponseWriter
 	Storage     Any
 	ContentType string
 }
 
+// Write writes the status and body, unless the client has gone away.
+// A request past its deadline is still answered, e.g. with a 504.
 func (c *Context) Write(code int, body []byte) error {
+	if c.Err() == context.Canceled {
+		return c.canceled()
+	}
 	c.Response.WriteHeader(code)
 	_, err := c.Response.Write(body)
 	return err
 }
 
+// Written reports whether the status or the body of the response has been written
+func (c *Context) Written() bool {
+	return c.writer.written
+}
+
+// responseWriter records whether the response has been written,
+// so that errors reported afterwards are not rendered into it
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		f.Flush()
+	}
+}
+
+// Unwrap returns the original writer, for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func (c *Context) JSON(code int, v interface{}) error {
 	c.Response.Header().Set("Content-Type", ContentType.JSON)
 	body, err := jsoniter.Marshal(v)
@@ -63,163 +178,325 @@ func (c *Context) JSON(code int, v interface{}) error {
 	return c.Write(code, body)
 }
 
+// Bind reads the body of POST, PUT, PATCH and DELETE requests by Content-Type,
+// and the query of GET, HEAD and body-less DELETE requests. Fields tagged
+// path:"id", header:"X-Tenant", cookie:"session" or query:"page" are then
+// read from their source for every method.
+//
+// Conversion and validation failures are returned as a *TransError on the first
+// failed field. Validation failures unwrap to every failed field:
+//
+//	var errs ValidationErrors
+//	if errors.As(err, &errs) { ... }
 func (c *Context) Bind(v interface{}) error {
-	if c.Request.Method == "POST" {
-		if c.ContentType == ContentType.JSON {
-			body := c.GetBody()
-			if len(body) == 0 {
-				body = []byte("{}")
-			}
-			if err := jsoniter.Unmarshal(body, v); err != nil {
+	switch c.Request.Method {
+	case "POST", "PUT", "PATCH":
+		if err := c.bindBody(v); err != nil {
+			return err
+		}
+	case "DELETE":
+		if c.ContentType == "" {
+			if err := c.bindForm(c.Request.URL.Query(), v); err != nil {
 				return err
 			}
-		} else if c.ContentType == ContentType.Form {
-			c.bindForm(c.Request.Form, reflect.TypeOf(v).Elem(), reflect.ValueOf(v).Elem())
-		} else {
-			return errors.New("unknown content type")
-		}
-	} else if c.Request.Method == "GET" {
-		c.bindForm(c.Request.URL.Query(), reflect.TypeOf(v).Elem(), reflect.ValueOf(v).Elem())
-	} else {
+		} else if err := c.bindBody(v); err != nil {
+			return err
+		}
+	case "GET", "HEAD":
+		if err := c.bindForm(c.Request.URL.Query(), v); err != nil {
+			return err
+		}
+	default:
 		return errors.New("unsupport http method")
 	}
-
-	c.setDefault(reflect.TypeOf(v).Elem(), reflect.ValueOf(v).Elem())
-	err := validate.Struct(v)
+	if err := c.bindSources(v); err != nil {
+		return err
+	}
+	b, err := getBinder(reflect.TypeOf(v).Elem())
 	if err != nil {
-		errs := err.(validator.ValidationErrors).Translate(trans)
-		for k, v := range errs {
-			return &TransError{
-				Message: v,
-				Field:   k,
-			}
-		}
+		return err
 	}
+	b.setDefault(reflect.ValueOf(v).Elem())
 
-	return nil
+	err = validate.Struct(v)
+	if errs, ok := err.(validator.ValidationErrors); ok {
+		var trans = translator(c.Lang())
+		var result = make(ValidationErrors, 0, len(errs))
+		for _, item := range errs {
+			result = append(result, &FieldError{
+				Field:   item.Field(),
+				Rule:    item.Tag(),
+				Param:   item.Param(),
+				Message: item.Translate(trans),
+			})
+		}
+		return result.First()
+	}
+	return err
 }
 
-func (c *Context) setDefault(typs reflect.Type, values reflect.Value) {
-	for i := 0; i < values.NumField(); i++ {
-		t := typs.Field(i)
-		v := values.Field(i)
+func (c *Context) bindBody(v interface{}) error {
+	if err := c.loadBody(); err != nil {
+		return err
+	}
 
-		if t.Name[0] >= 'a' && t.Name[0] <= 'z' {
-			continue
+	if c.ContentType == ContentType.JSON {
+		body := c.GetBody()
+		if len(body) == 0 {
+			body = []byte("{}")
 		}
-
-		var kind = t.Type.Kind()
-		if kind == reflect.Struct {
-			c.setDefault(t.Type, v)
-			continue
-		} else if kind.String() == "ptr" {
-			item := v.Interface()
-			c.setDefault(reflect.TypeOf(item).Elem(), reflect.ValueOf(item).Elem())
-			continue
+		return jsoniter.Unmarshal(body, v)
+	} else if c.ContentType == ContentType.Form {
+		return c.bindForm(c.Request.Form, v)
+	} else if c.ContentType == ContentType.Multipart {
+		if err := c.bindForm(c.Request.Form, v); err != nil {
+			return err
 		}
+		b, _ := getBinder(reflect.TypeOf(v).Elem())
+		b.bindFiles(c.Request.MultipartForm.File, reflect.ValueOf(v).Elem())
+		return nil
+	}
+	return errors.New("unknown content type")
+}
 
-		val := t.Tag.Get("default")
-		if val == "" {
-			continue
+// loadBody reads the request body on the first call of GetBody, Bind or FormFile
+// and stores it in Storage["body"]. Forms are parsed into Request.Form instead.
+func (c *Context) loadBody() (err error) {
+	if c.bodyLoaded {
+		return c.bodyErr
+	}
+	c.bodyLoaded = true
+
+	var body = []byte("")
+	defer func() {
+		c.Storage.Set("body", body)
+		// form parsers may wrap the error of the body reader
+		if errors.Is(err, ErrBodyTooLarge) {
+			err = ErrBodyTooLarge
 		}
-		switch kind {
-		case reflect.Bool:
-			if v.Bool() == false && val == "true" {
-				v.SetBool(true)
-			}
-		case reflect.String:
-			if v.String() == "" {
-				v.SetString(val)
-			}
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			if v.Int() == 0 {
-				v.SetInt(ToInt(val))
-			}
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			if v.Uint() == 0 {
-				v.SetUint(uint64(ToInt(val)))
+		c.bodyErr = err
+	}()
+
+	if c.stream {
+		err = ErrBodyStreamed
+		return err
+	}
+
+	switch c.ContentType {
+	case "":
+	case ContentType.Form:
+		// ParseForm only reads the body of POST, PUT and PATCH requests
+		if c.Request.Method == "DELETE" {
+			var buf = bytes.NewBufferString("")
+			if _, err = io.Copy(buf, c.Request.Body); err != nil {
+				return err
 			}
+			c.Request.PostForm, _ = url.ParseQuery(buf.String())
+		}
+		if err = c.Request.ParseForm(); err == nil {
+			body = []byte(c.Request.Form.Encode())
+		}
+	case ContentType.Multipart:
+		if err = c.Request.ParseMultipartForm(multipartMemory); err == nil {
+			body = []byte(c.Request.Form.Encode())
+		}
+	default:
+		var buf = bytes.NewBufferString("")
+		if _, err = io.Copy(buf, c.Request.Body); err == nil {
+			body = buf.Bytes()
 		}
 	}
+	return err
 }
 
-func (c *Context) bindForm(query url.Values, typs reflect.Type, values reflect.Value) {
-	for i := 0; i < values.NumField(); i++ {
-		t := typs.Field(i)
-		v := values.Field(i)
+func (c *Context) bindForm(query url.Values, v interface{}) error {
+	b, err := getBinder(reflect.TypeOf(v).Elem())
+	if err != nil {
+		return err
+	}
+	_, err = b.bindValues(query, "", reflect.ValueOf(v).Elem())
+	return err
+}
 
-		if t.Name[0] >= 'a' && t.Name[0] <= 'z' {
-			continue
-		}
+// bindSources sets the fields tagged path, header, cookie or query, whatever the method
+func (c *Context) bindSources(v interface{}) error {
+	b, err := getBinder(reflect.TypeOf(v).Elem())
+	if err != nil {
+		return err
+	}
 
-		var kind = t.Type.Kind()
-		if kind == reflect.Struct {
-			c.bindForm(query, t.Type, v)
-			continue
-		} else if kind == reflect.Ptr {
-			item := v.Interface()
-			c.bindForm(query, reflect.TypeOf(item).Elem(), reflect.ValueOf(item).Elem())
-			continue
-		}
+	var path = url.Values{}
+	for _, p := range c.params {
+		path.Add(p.Key, p.Value)
+	}
+	var cookie = url.Values{}
+	for _, item := range c.Request.Cookies() {
+		cookie.Add(item.Name, item.Value)
+	}
 
-		tag := t.Tag.Get("json")
-		if tag == "" {
-			tag = t.Name
-		}
-		var vals []string
-		var ok bool
-		if kind == reflect.Slice {
-			vals, ok = query[tag+"[]"]
-		} else {
-			vals, ok = query[tag]
-		}
-		if !ok || len(vals) == 0 {
-			continue
+	for _, item := range []struct {
+		source string
+		values url.Values
+	}{
+		{"path", path},
+		{"header", url.Values(c.Request.Header)},
+		{"cookie", cookie},
+		{"query", c.Request.URL.Query()},
+	} {
+		if _, err := b.bindValues(item.values, item.source, reflect.ValueOf(v).Elem()); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		var val = vals[0]
-		switch kind {
-		case reflect.String:
-			v.SetString(val)
-		case reflect.Bool:
-			if val == "true" {
-				v.SetBool(true)
-			} else if val == "false" {
-				v.SetBool(false)
-			}
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			v.SetInt(ToInt(val))
-		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			v.SetUint(uint64(ToInt(val)))
-		case reflect.Slice:
-			var vi = v.Interface()
-			if arr, success := vi.([]int64); success {
-				arr = make([]int64, 0)
-				for i, _ := range vals {
-					arr = append(arr, ToInt(vals[i]))
-				}
-				v.Set(reflect.ValueOf(arr))
-				continue
-			}
+// Lang returns the language of the request, used by Bind for validation messages.
+// It is chosen by the query parameter and cookie named with SetLangKey, then by
+// Accept-Language, and falls back to the language set with SetLang.
+func (c *Context) Lang() Lang {
+	if c.langLoaded {
+		return c.lang
+	}
+	c.langLoaded = true
+	c.lang = Lang(atomic.LoadUint32(&defaultLang))
 
-			if arr, success := vi.([]string); success {
-				arr = make([]string, 0)
-				for i, _ := range vals {
-					arr = append(arr, vals[i])
-				}
-				v.Set(reflect.ValueOf(arr))
-				continue
+	if langQuery != "" {
+		if lang, ok := matchLang(c.Request.URL.Query().Get(langQuery)); ok {
+			c.lang = lang
+			return c.lang
+		}
+	}
+	if langCookie != "" {
+		if item, err := c.Request.Cookie(langCookie); err == nil {
+			if lang, ok := matchLang(item.Value); ok {
+				c.lang = lang
+				return c.lang
 			}
 		}
 	}
+	if lang, ok := parseAcceptLanguage(c.Request.Header.Get("Accept-Language")); ok {
+		c.lang = lang
+	}
+	return c.lang
+}
+
+// handlerError is an error reported by ctx.Error, with the handler reporting it
+type handlerError struct {
+	err     error
+	handler string
 }
 
+// Error reports err to the error chain of the server, which runs once the
+// handlers return, on the last error reported. It does not abort the chain.
+// The function calling Error is recorded as the handler reporting it, so
+// middleware reporting after Next returns is named, not the last handler.
+func (c *Context) Error(err error) {
+	if err == nil {
+		return
+	}
+	var name string
+	if pc, _, _, ok := runtime.Caller(1); ok {
+		name = runtime.FuncForPC(pc).Name()
+	}
+	c.addError(err, name)
+}
+
+func (c *Context) addError(err error, handler string) {
+	c.errors = append(c.errors, handlerError{err: err, handler: handler})
+}
+
+// Errors returns the errors reported with Error, in order
+func (c *Context) Errors() []error {
+	var errs = make([]error, 0, len(c.errors))
+	for _, item := range c.errors {
+		errs = append(errs, item.err)
+	}
+	return errs
+}
+
+// Next runs the remaining handlers of the chain and returns, so middleware can
+// act both before and after them. Handlers that never call Next are followed
+// by the rest of the chain automatically. Once the request is canceled or past
+// its deadline, the chain stops and the error is reported to the error chain.
 func (c *Context) Next() {
-	c.next = true
+	c.index++
+	for c.index < len(c.handlers) {
+		if err := c.canceled(); err != nil {
+			c.addError(err, "")
+			c.Abort()
+			return
+		}
+		c.handlers[c.index](c)
+		c.index++
+	}
 }
 
+// Abort prevents the pending handlers from being called
 func (c *Context) Abort() {
-	c.next = false
+	c.index = abortIndex
+}
+
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// Deadline, Done, Err and Value make Context a context.Context backed by the
+// request context, so it can be passed to downstream calls directly.
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	return c.Request.Context().Deadline()
+}
+
+func (c *Context) Done() <-chan struct{} {
+	return c.Request.Context().Done()
+}
+
+func (c *Context) Err() error {
+	return c.Request.Context().Err()
+}
+
+// Value looks up string keys in Storage first, then in the request context
+func (c *Context) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if v, exist := c.Storage.Get(k); exist {
+			return v
+		}
+	}
+	return c.Request.Context().Value(key)
+}
+
+// SetContext replaces the request context, e.g. to attach values or a deadline
+func (c *Context) SetContext(ctx context.Context) {
+	c.Request = c.Request.WithContext(ctx)
+}
+
+// canceled returns a *Error when the client has gone away or the deadline has passed
+func (c *Context) canceled() error {
+	switch c.Request.Context().Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return NewError(DeadlineExceeded, "deadline exceeded")
+	default:
+		return NewError(Canceled, "request canceled")
+	}
+}
+
+// httpStatus returns the status of err: its own one, like 413 for ErrBodyTooLarge,
+// else the status of its code mapped by the server
+func (c *Context) httpStatus(err *Error) int {
+	if err.status != 0 {
+		return err.status
+	}
+	if c.server != nil && c.server.statusOf != nil {
+		return c.server.statusOf(err.Code)
+	}
+	return err.Code.HTTPStatus()
+}
+
+// Param returns the value of the named path parameter, e.g. id for /users/:id
+func (c *Context) Param(key string) string {
+	v, _ := c.params.Get(key)
+	return v
 }
 
 func (c *Context) ClientIP() string {
@@ -238,7 +515,44 @@ func (c *Context) ClientIP() string {
 	return host
 }
 
+// FormFile returns the first uploaded file of a multipart/form-data request
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if err := c.loadBody(); err != nil {
+		return nil, err
+	}
+	if c.Request.MultipartForm == nil {
+		return nil, http.ErrNotMultipart
+	}
+	if fhs := c.Request.MultipartForm.File[name]; len(fhs) > 0 {
+		return fhs[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile writes the uploaded file to dst
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, src)
+	return err
+}
+
+// GetBody returns the request body, reading it on the first call.
+// A body over the size limit throws ErrBodyTooLarge to the error chain.
 func (c *Context) GetBody() []byte {
+	if err := c.loadBody(); err == ErrBodyTooLarge || err == ErrBodyStreamed {
+		Throw(err)
+	}
 	v, _ := c.Storage.Get("body")
 	return v.([]byte)
 }
@@ -0,0 +1,367 @@
+package fastapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func TestContextValue(t *testing.T) {
+	var req = httptest.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "request"))
+	var ctx = newContext(req, httptest.NewRecorder())
+	ctx.Storage.Set("user", "storage")
+
+	var c context.Context = ctx
+	if v := c.Value("user"); v != "storage" {
+		t.Errorf("Value(user) = %v", v)
+	}
+	if v := c.Value(ctxKey{}); v != "request" {
+		t.Errorf("Value(ctxKey) = %v", v)
+	}
+
+	deadline, cancel := context.WithTimeout(ctx, time.Hour)
+	defer cancel()
+	ctx.SetContext(deadline)
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("SetContext lost the deadline")
+	}
+}
+
+func TestContextCanceled(t *testing.T) {
+	var s = New()
+	var reached = false
+	s.GET("/r", func(ctx *Context) {
+		cancelCtx, cancel := context.WithCancel(ctx.Request.Context())
+		ctx.SetContext(cancelCtx)
+		cancel()
+	}, func(ctx *Context) {
+		reached = true
+	})
+
+	if w := request(s, "GET", "/r", nil); w.Code != 200 || w.Body.Len() != 0 {
+		t.Errorf("canceled request answered %d %q", w.Code, w.Body.String())
+	}
+	if reached {
+		t.Error("the chain went on after the request was canceled")
+	}
+
+	var req = httptest.NewRequest("GET", "/", nil)
+	cancelCtx, cancel := context.WithCancel(req.Context())
+	cancel()
+	var ctx = newContext(req.WithContext(cancelCtx), httptest.NewRecorder())
+	if err, ok := ctx.Write(200, nil).(*Error); !ok || err.Code != Canceled {
+		t.Errorf("Write returned %v", err)
+	}
+	<-ctx.Done()
+}
+
+func TestContextNext(t *testing.T) {
+	var s = New()
+	var trace []string
+	s.Use(func(ctx *Context) {
+		trace = append(trace, "before")
+		ctx.Next()
+		trace = append(trace, "after")
+	})
+	s.GET("/r", func(ctx *Context) {
+		trace = append(trace, "first")
+	}, func(ctx *Context) {
+		trace = append(trace, "second")
+	})
+	s.GET("/abort", func(ctx *Context) {
+		trace = append(trace, "abort")
+		ctx.Abort()
+		if !ctx.IsAborted() {
+			t.Error("IsAborted is false after Abort")
+		}
+	}, func(ctx *Context) {
+		trace = append(trace, "unreachable")
+	})
+
+	request(s, "GET", "/r", nil)
+	if got := strings.Join(trace, ","); got != "before,first,second,after" {
+		t.Errorf("trace %s", got)
+	}
+
+	trace = nil
+	request(s, "GET", "/abort", nil)
+	if got := strings.Join(trace, ","); got != "before,abort,after" {
+		t.Errorf("trace %s", got)
+	}
+}
+
+type bindForm struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+// bindEcho binds a bindForm and writes it back
+func bindEcho(ctx *Context) {
+	var form bindForm
+	if err := ctx.Bind(&form); err != nil {
+		ctx.Write(400, []byte(err.Error()))
+		return
+	}
+	ctx.JSON(200, &form)
+}
+
+func TestBindMethods(t *testing.T) {
+	var s = New()
+	s.ANY("/r", bindEcho)
+
+	const want = `{"name":"x","age":3}`
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		w := request(s, method, "/r", strings.NewReader(want), "Content-Type", ContentType.JSON)
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s json: %d %s", method, w.Code, w.Body.String())
+		}
+		w = request(s, method, "/r", strings.NewReader("name=x&age=3"), "Content-Type", ContentType.Form)
+		if w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s form: %d %s", method, w.Code, w.Body.String())
+		}
+	}
+	for _, method := range []string{"GET", "HEAD", "DELETE"} {
+		if w := request(s, method, "/r?name=x&age=3", nil); w.Code != 200 || w.Body.String() != want {
+			t.Errorf("%s query: %d %s", method, w.Code, w.Body.String())
+		}
+	}
+
+	if w := request(s, "OPTIONS", "/r", nil); w.Code != 400 {
+		t.Errorf("OPTIONS: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "POST", "/r", strings.NewReader("x"), "Content-Type", "text/plain"); w.Code != 400 {
+		t.Errorf("text/plain: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "POST", "/r", nil, "Content-Type", ContentType.JSON); w.Code != 200 || w.Body.String() != `{"name":"","age":0}` {
+		t.Errorf("empty json: %d %s", w.Code, w.Body.String())
+	}
+}
+
+type uploadForm struct {
+	Title  string                  `json:"title"`
+	Avatar *multipart.FileHeader   `json:"avatar"`
+	Photos []*multipart.FileHeader `json:"photos"`
+}
+
+// multipartBody encodes fields and files, given as name, filename, content triples
+func multipartBody(t *testing.T, fields map[string]string, files ...string) (*bytes.Buffer, string) {
+	var buf = bytes.NewBufferString("")
+	var writer = multipart.NewWriter(buf)
+	for k, v := range fields {
+		writer.WriteField(k, v)
+	}
+	for i := 0; i+2 < len(files); i += 3 {
+		part, err := writer.CreateFormFile(files[i], files[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(files[i+2]))
+	}
+	writer.Close()
+	return buf, writer.FormDataContentType()
+}
+
+func TestBindMultipart(t *testing.T) {
+	var s = New()
+	var dir, _ = ioutil.TempDir("", "fastapi")
+	defer os.RemoveAll(dir)
+
+	s.POST("/upload", func(ctx *Context) {
+		var form uploadForm
+		if err := ctx.Bind(&form); err != nil {
+			t.Fatal(err)
+		}
+		if form.Title != "trip" || form.Avatar == nil || form.Avatar.Filename != "a.png" || len(form.Photos) != 2 {
+			t.Fatalf("form %+v", form)
+		}
+		if fh, err := ctx.FormFile("avatar"); err != nil || fh != form.Avatar {
+			t.Errorf("FormFile(avatar) = %v, %v", fh, err)
+		}
+		if _, err := ctx.FormFile("none"); err != http.ErrMissingFile {
+			t.Errorf("FormFile(none) = %v", err)
+		}
+		var dst = filepath.Join(dir, "a.png")
+		if err := ctx.SaveUploadedFile(form.Avatar, dst); err != nil {
+			t.Fatal(err)
+		}
+		if content, _ := ioutil.ReadFile(dst); string(content) != "avatar" {
+			t.Errorf("saved %q", content)
+		}
+		ctx.Write(200, nil)
+	})
+
+	body, contentType := multipartBody(t, map[string]string{"title": "trip"},
+		"avatar", "a.png", "avatar",
+		"photos[]", "1.png", "1",
+		"photos[]", "2.png", "2",
+	)
+	if w := request(s, "POST", "/upload", body, "Content-Type", contentType); w.Code != 200 {
+		t.Errorf("upload: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFormFileNotMultipart(t *testing.T) {
+	var req = httptest.NewRequest("POST", "/", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", ContentType.JSON)
+	var ctx = newContext(req, httptest.NewRecorder())
+	if _, err := ctx.FormFile("avatar"); err != http.ErrNotMultipart {
+		t.Errorf("FormFile = %v", err)
+	}
+}
+
+type sourceForm struct {
+	ID      int      `path:"id"`
+	Tenant  string   `header:"x-tenant"`
+	Session string   `cookie:"session"`
+	Page    int      `query:"page"`
+	Tags    []string `query:"tag"`
+	Name    string   `json:"name"`
+}
+
+func TestBindSources(t *testing.T) {
+	var s = New()
+	var form sourceForm
+	s.ANY("/users/:id", func(ctx *Context) {
+		form = sourceForm{}
+		if err := ctx.Bind(&form); err != nil {
+			ctx.Write(400, []byte(err.Error()))
+		}
+	})
+
+	for _, method := range []string{"GET", "POST"} {
+		var body = strings.NewReader(`{"name":"x"}`)
+		w := request(s, method, "/users/7?page=2&tag=a&tag=b&name=x", body,
+			"Content-Type", ContentType.JSON,
+			"X-Tenant", "acme",
+			"Cookie", "session=abc",
+		)
+		if w.Code != 200 {
+			t.Fatalf("%s: %d %s", method, w.Code, w.Body.String())
+		}
+		if form.ID != 7 || form.Tenant != "acme" || form.Session != "abc" || form.Page != 2 ||
+			strings.Join(form.Tags, ",") != "a,b" || form.Name != "x" {
+			t.Errorf("%s: %+v", method, form)
+		}
+	}
+
+	if w := request(s, "GET", "/users/x", nil); w.Code != 400 || w.Body.String() != "invalid value for id" {
+		t.Errorf("invalid path: %d %s", w.Code, w.Body.String())
+	}
+}
+
+type signupForm struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email" validate:"required,email"`
+	Age   int    `json:"age" validate:"min=18"`
+}
+
+func TestBindValidationErrors(t *testing.T) {
+	var err = bindQuery(&signupForm{}, "/?email=x&age=20")
+	first, ok := err.(*TransError)
+	if !ok || first.Field != "name" {
+		t.Fatalf("Bind returned %#v", err)
+	}
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Field != "name" || errs[1].Field != "email" || errs[1].Rule != "email" {
+		t.Fatalf("ValidationErrors %v", errs)
+	}
+	if first.Message != errs[0].Message {
+		t.Errorf("message %q, want %q", first.Message, errs[0].Message)
+	}
+
+	var plain = &TransError{Field: "page", Message: "invalid value for page"}
+	if errors.Unwrap(plain) != nil {
+		t.Error("a conversion error unwraps")
+	}
+
+	var s = New()
+	s.GET("/signup", HandlerE(func(ctx *Context) error {
+		return ctx.Bind(&signupForm{})
+	}))
+	w := request(s, "GET", "/signup?email=x&age=20", nil)
+	if w.Code != 400 || !strings.Contains(w.Body.String(), `"details":[{"field":"name","rule":"required"`) ||
+		!strings.Contains(w.Body.String(), `{"field":"email","rule":"email"`) {
+		t.Errorf("%d %s", w.Code, w.Body.String())
+	}
+
+	s.SetCatch(func(ctx *Context, err interface{}) {
+		if e, ok := err.(*TransError); ok {
+			ctx.Write(422, []byte(e.Field))
+		}
+	})
+	if w := request(s, "GET", "/signup", nil); w.Code != 422 || w.Body.String() != "name" {
+		t.Errorf("custom catch: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	var s = New()
+	var reached = false
+	var chained error
+	s.OnError(func(ctx *Context, err error) error {
+		chained = err
+		return err
+	})
+	s.Use(func(ctx *Context) {
+		deadline, cancel := context.WithTimeout(ctx.Request.Context(), time.Millisecond)
+		defer cancel()
+		ctx.SetContext(deadline)
+		<-deadline.Done()
+		ctx.Next()
+	})
+	s.GET("/slow", func(ctx *Context) {
+		reached = true
+	})
+
+	w := request(s, "GET", "/slow", nil)
+	if w.Code != 504 || w.Body.String() != `{"code":4,"msg":"deadline exceeded"}` {
+		t.Errorf("%d %s", w.Code, w.Body.String())
+	}
+	if reached {
+		t.Error("the chain went on after the deadline")
+	}
+	if e, ok := chained.(*Error); !ok || e.Code != DeadlineExceeded {
+		t.Errorf("error chain got %v", chained)
+	}
+}
+
+func TestMultipartTempFiles(t *testing.T) {
+	var dir, _ = ioutil.TempDir("", "fastapi")
+	defer os.RemoveAll(dir)
+	var tmp = os.Getenv("TMPDIR")
+	os.Setenv("TMPDIR", dir)
+	defer os.Setenv("TMPDIR", tmp)
+	SetMultipartMemory(1)
+	defer SetMultipartMemory(32 << 20)
+
+	var s = New()
+	s.Use(func(ctx *Context) {
+		ctx.SetContext(context.WithValue(ctx.Request.Context(), ctxKey{}, "copy"))
+	})
+	s.POST("/upload", func(ctx *Context) {
+		if _, err := ctx.FormFile("file"); err != nil {
+			t.Fatal(err)
+		}
+		if files, _ := ioutil.ReadDir(dir); len(files) == 0 {
+			t.Error("the upload is not stored on disk")
+		}
+	})
+
+	body, contentType := multipartBody(t, nil, "file", "a.txt", strings.Repeat("x", 1024))
+	request(s, "POST", "/upload", body, "Content-Type", contentType)
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Errorf("%d temporary files left", len(files))
+	}
+}
@@ -0,0 +1,117 @@
+package fastapi
+
+import (
+	"github.com/json-iterator/go"
+	"github.com/swaggo/files"
+	"html"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+const swaggerIndex = `<!DOCTYPE html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8">
+    <title>{{title}}</title>
+    <link rel="stylesheet" type="text/css" href="{{base}}/swagger-ui.css" />
+    <link rel="stylesheet" type="text/css" href="{{base}}/index.css" />
+    <link rel="icon" type="image/png" href="{{base}}/favicon-32x32.png" sizes="32x32" />
+    <link rel="icon" type="image/png" href="{{base}}/favicon-16x16.png" sizes="16x16" />
+  </head>
+
+  <body>
+    <div id="swagger-ui"></div>
+    <script src="{{base}}/swagger-ui-bundle.js" charset="UTF-8"> </script>
+    <script src="{{base}}/swagger-ui-standalone-preset.js" charset="UTF-8"> </script>
+    <script>
+      window.onload = function() {
+        window.ui = SwaggerUIBundle({
+          url: {{url}},
+          dom_id: '#swagger-ui',
+          deepLinking: true,
+          presets: [
+            SwaggerUIBundle.presets.apis,
+            SwaggerUIStandalonePreset
+          ],
+          plugins: [
+            SwaggerUIBundle.plugins.DownloadUrl
+          ],
+          layout: "StandaloneLayout"
+        });
+      };
+    </script>
+  </body>
+</html>
+`
+
+// Docs serves Swagger UI at path. The assets are compiled into the binary, so
+// the page works without internet access. spec is the OpenAPI document to
+// browse: a string is used as its URL, an *OpenAPI or []byte is served at
+// path/openapi.json, and nil serves the document generated from the routes.
+// The pages answer 404 in ProductMode.
+func (s *Server) Docs(path string, spec interface{}) {
+	var base = strings.TrimSuffix(path, "/")
+	var opt = &RouteOption{hidden: true}
+	var guard = func(ctx *Context) {
+		if globalMode == ProductMode {
+			s.notFound(ctx)
+		}
+	}
+
+	var url, ok = spec.(string)
+	if !ok {
+		url = base + "/openapi.json"
+		s.HandleWith("GET", url, opt, guard, func(ctx *Context) {
+			switch doc := spec.(type) {
+			case []byte:
+				ctx.Response.Header().Set("Content-Type", ContentType.JSON)
+				ctx.Write(200, doc)
+			case *OpenAPI:
+				ctx.JSON(200, doc)
+			default:
+				ctx.JSON(200, s.OpenAPI(nil))
+			}
+		})
+	}
+
+	quoted, _ := jsoniter.MarshalToString(url)
+	var index = strings.NewReplacer(
+		"{{title}}", "API Docs",
+		"{{base}}", html.EscapeString(base),
+		"{{url}}", quoted,
+	).Replace(swaggerIndex)
+
+	var serveIndex = func(ctx *Context) {
+		ctx.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+		ctx.Write(200, []byte(index))
+	}
+	s.HandleWith("GET", base, opt, guard, serveIndex)
+	s.HandleWith("GET", base+"/:file", opt, guard, func(ctx *Context) {
+		var name = ctx.Param("file")
+		if name == "index.html" {
+			serveIndex(ctx)
+			return
+		}
+
+		body, err := swaggerFiles.ReadFile("/" + name)
+		if err != nil {
+			s.notFound(ctx)
+			return
+		}
+		ctx.Response.Header().Set("Content-Type", assetType(name))
+		ctx.Write(200, body)
+	})
+}
+
+// assetType returns the Content-Type of a Swagger UI asset. Source maps are JSON.
+func assetType(name string) string {
+	var ext = filepath.Ext(name)
+	if ext == ".map" {
+		return ContentType.JSON
+	}
+	if typ := mime.TypeByExtension(ext); typ != "" {
+		return typ
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,79 @@
+package fastapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDocs(t *testing.T) {
+	var s = New()
+	s.GET("/users", noop)
+	s.Docs("/docs", nil)
+
+	w := request(s, "GET", "/docs", nil)
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `url: "/docs/openapi.json"`) {
+		t.Errorf("index: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/docs/index.html", nil); w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("index.html: %d %v", w.Code, w.Header())
+	}
+	if w := request(s, "GET", "/docs/swagger-ui.css", nil); w.Code != 200 || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("swagger-ui.css: %d %v", w.Code, w.Header())
+	}
+	if w := request(s, "GET", "/docs/swagger-ui-bundle.js.map", nil); w.Code != 200 || w.Header().Get("Content-Type") != ContentType.JSON {
+		t.Errorf("swagger-ui-bundle.js.map: %d %v", w.Code, w.Header())
+	}
+	if w := request(s, "GET", "/docs/none.js", nil); w.Code != 404 {
+		t.Errorf("none.js: %d", w.Code)
+	}
+
+	w = request(s, "GET", "/docs/openapi.json", nil)
+	var doc OpenAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || doc.Paths["/users"]["get"] == nil {
+		t.Errorf("openapi.json: %v %s", err, w.Body.String())
+	}
+	if _, ok := doc.Paths["/docs"]; ok {
+		t.Error("the docs are documented")
+	}
+}
+
+func TestDocsSpec(t *testing.T) {
+	var s = New()
+	s.Docs("/raw", []byte(`{"openapi":"3.0.3"}`))
+	s.Docs("/doc", &OpenAPI{OpenAPI: "3.0.0"})
+	s.Docs("/url", "https://example.com/openapi.json")
+
+	if w := request(s, "GET", "/raw/openapi.json", nil); w.Body.String() != `{"openapi":"3.0.3"}` || w.Header().Get("Content-Type") != ContentType.JSON {
+		t.Errorf("raw: %s %v", w.Body.String(), w.Header())
+	}
+	if w := request(s, "GET", "/doc/openapi.json", nil); !strings.Contains(w.Body.String(), `"openapi":"3.0.0"`) {
+		t.Errorf("doc: %s", w.Body.String())
+	}
+	if w := request(s, "GET", "/url/openapi.json", nil); w.Code != 404 {
+		t.Errorf("url: spec served with %d", w.Code)
+	}
+	if w := request(s, "GET", "/url", nil); !strings.Contains(w.Body.String(), `url: "https://example.com/openapi.json"`) {
+		t.Errorf("url: %s", w.Body.String())
+	}
+}
+
+func TestDocsProductMode(t *testing.T) {
+	SetMode(ProductMode)
+	defer SetMode(DebugMode)
+
+	var s = New()
+	var global = 0
+	s.Use(func(ctx *Context) {
+		global++
+	})
+	s.NoRoute(reply("custom"))
+	s.Docs("/docs", nil)
+
+	for _, path := range []string{"/docs", "/docs/swagger-ui.css", "/docs/openapi.json"} {
+		global = 0
+		if w := request(s, "GET", path, nil); w.Body.String() != "custom" || global != 1 {
+			t.Errorf("%s: %d %s, global middleware ran %d times", path, w.Code, w.Body.String(), global)
+		}
+	}
+}
@@ -1,34 +1,206 @@
 package fastapi
 
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+)
+
 type TransError struct {
 	Message string
 	Field   string
+
+	// errs are all the failed fields when the error comes from validation
+	errs ValidationErrors
 }
 
 func (this *TransError) Error() string {
 	return this.Message
 }
 
+// Unwrap returns every failed field when the error comes from validation,
+// so errors.As(err, &ValidationErrors{}) reports them all
+func (this *TransError) Unwrap() error {
+	if len(this.errs) == 0 {
+		return nil
+	}
+	return this.errs
+}
+
+// FieldError is a validation failure of one field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every field that failed validation, in struct field order
+type ValidationErrors []*FieldError
+
+func (this ValidationErrors) Error() string {
+	var messages = make([]string, 0, len(this))
+	for _, item := range this {
+		messages = append(messages, item.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// First returns the first failure as a *TransError, which unwraps to all of them
+func (this ValidationErrors) First() *TransError {
+	if len(this) == 0 {
+		return nil
+	}
+	return &TransError{Field: this[0].Field, Message: this[0].Message, errs: this}
+}
+
 func NewError(code Code, msg string) *Error {
-	return &Error{Code: code, Msg: msg}
+	return &Error{Code: code, Msg: msg, stack: callers(1)}
 }
 
+// Error is the error of a request, written to the client as its code and message
+// with the optional details and metadata. The cause and stack are never written,
+// the default catcher logs them in DebugMode.
 type Error struct {
 	Code Code   `json:"code"`
 	Msg  string `json:"msg"`
+
+	// Details describe the error for programs, like gRPC status details,
+	// e.g. *FieldError for field violations or RetryInfo
+	Details []interface{} `json:"details,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// status overrides the http status written by the default catcher
+	status int
+
+	cause error
+	stack []uintptr
 }
 
+// RetryInfo is a detail telling clients how long to wait before retrying
+type RetryInfo struct {
+	RetryDelay time.Duration
+}
+
+func (this RetryInfo) MarshalJSON() ([]byte, error) {
+	return []byte(`{"retryDelay":"` + this.RetryDelay.String() + `"}`), nil
+}
+
+var (
+	ErrBodyTooLarge = &Error{Code: ResourceExhausted, Msg: "request body too large", status: 413}
+	ErrBodyStreamed = &Error{Code: FailedPrecondition, Msg: "request body is read as a stream", status: 500}
+)
+
+func (this *Error) clone() *Error {
+	var e = *this
+	e.Details = append([]interface{}(nil), this.Details...)
+	if this.Metadata != nil {
+		e.Metadata = make(map[string]string, len(this.Metadata))
+		for k, v := range this.Metadata {
+			e.Metadata[k] = v
+		}
+	}
+	e.stack = callers(2)
+	return &e
+}
+
+// Wrap returns a copy of the error with another message, caused by the error
 func (this *Error) Wrap(msg string) *Error {
-	return &Error{
-		Code: this.Code,
-		Msg:  msg,
+	var e = this.clone()
+	e.Msg = msg
+	e.cause = this
+	return e
+}
+
+// WithCause returns a copy of the error caused by err, which errors.Is and errors.As find
+func (this *Error) WithCause(err error) *Error {
+	var e = this.clone()
+	e.cause = err
+	return e
+}
+
+// WithDetails returns a copy of the error with the details appended
+func (this *Error) WithDetails(details ...interface{}) *Error {
+	var e = this.clone()
+	e.Details = append(e.Details, details...)
+	return e
+}
+
+// WithMetadata returns a copy of the error with the metadata key set
+func (this *Error) WithMetadata(key string, value string) *Error {
+	var e = this.clone()
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
 	}
+	e.Metadata[key] = value
+	return e
 }
 
+// Error returns the message alone, the cause is left to Unwrap and %+v
 func (this *Error) Error() string {
 	return this.Msg
 }
 
+func (this *Error) Unwrap() error {
+	return this.cause
+}
+
+// Is reports whether target is an *Error of the same code,
+// with the same message unless the message of target is empty
+func (this *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Code == this.Code && (t.Msg == "" || t.Msg == this.Msg)
+}
+
+// Format prints the cause and the stack with %+v, like pkg/errors
+func (this *Error) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		io.WriteString(s, this.Code.String()+": "+this.Msg)
+		var frames = runtime.CallersFrames(this.stack)
+		for {
+			frame, more := frames.Next()
+			if frame.Function != "" {
+				fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+			}
+			if !more {
+				break
+			}
+		}
+		if this.cause != nil {
+			fmt.Fprintf(s, "\ncaused by: %+v", this.cause)
+		}
+	case verb == 's' || verb == 'v':
+		io.WriteString(s, this.Error())
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", this.Error())
+	}
+}
+
+// callers captures the stack above the skip callers of callers, in DebugMode only
+func callers(skip int) []uintptr {
+	if globalMode != DebugMode {
+		return nil
+	}
+	var pcs = make([]uintptr, 32)
+	var n = runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+// thrown marks the panics of Throw, the only ones ServeHTTP hands to the error chain
+type thrown struct {
+	err error
+}
+
+// Throw panics with err, which ServeHTTP recovers and hands to the error chain.
+// Other panics, even with an error, go to the catch function.
+// Handlers should prefer returning errors with HandlerE or reporting them with ctx.Error.
 func Throw(err error) {
-	panic(err)
+	panic(&thrown{err: err})
 }
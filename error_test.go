@@ -0,0 +1,79 @@
+package fastapi
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorWrap(t *testing.T) {
+	var base = NewError(NotFound, "user not found")
+	var wrapped = base.Wrap("profile not found")
+	if wrapped.Error() != "profile not found" || wrapped.Code != NotFound {
+		t.Errorf("Wrap: %s %v", wrapped.Error(), wrapped.Code)
+	}
+	if errors.Unwrap(wrapped) != base {
+		t.Error("Wrap lost the cause")
+	}
+	// pkg/errors.Cause unwraps to the *Error itself, not to its nil cause
+	if errors.Cause(errors.Wrap(base, "load")) != base {
+		t.Error("errors.Cause does not stop at the error")
+	}
+	if base.Error() != "user not found" {
+		t.Errorf("Wrap changed the original: %s", base.Error())
+	}
+
+	var caused = NewError(Internal, "internal error").WithCause(io.ErrUnexpectedEOF)
+	if caused.Error() != "internal error" || fmt.Sprintf("%v", caused) != "internal error" {
+		t.Errorf("WithCause changed the message: %s", caused.Error())
+	}
+	if !errors.Is(caused, io.ErrUnexpectedEOF) {
+		t.Error("errors.Is does not find the cause")
+	}
+	if s := fmt.Sprintf("%+v", caused); !strings.HasPrefix(s, "INTERNAL: internal error") || !strings.Contains(s, "caused by: unexpected EOF") {
+		t.Errorf("%%+v: %s", s)
+	}
+}
+
+func TestErrorIsAs(t *testing.T) {
+	var err error = errors.Wrap(NewError(NotFound, "user not found"), "load")
+	if !errors.Is(err, &Error{Code: NotFound}) || !errors.Is(err, &Error{Code: NotFound, Msg: "user not found"}) {
+		t.Error("errors.Is does not match the code")
+	}
+	if errors.Is(err, &Error{Code: NotFound, Msg: "other"}) || errors.Is(err, &Error{Code: Internal}) {
+		t.Error("errors.Is matches another error")
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Code != NotFound {
+		t.Errorf("errors.As: %v", e)
+	}
+}
+
+func TestErrorCopies(t *testing.T) {
+	var base = NewError(ResourceExhausted, "quota").WithMetadata("zone", "a")
+	var other = base.WithMetadata("zone", "b").WithDetails(RetryInfo{RetryDelay: time.Second})
+	if base.Metadata["zone"] != "a" || len(base.Details) != 0 {
+		t.Errorf("the original changed: %+v", base)
+	}
+	if other.Metadata["zone"] != "b" || len(other.Details) != 1 {
+		t.Errorf("copy %+v", other)
+	}
+}
+
+func TestErrorRenderMetadata(t *testing.T) {
+	var s = New()
+	s.GET("/quota", HandlerE(func(ctx *Context) error {
+		return NewError(ResourceExhausted, "quota exceeded").
+			WithMetadata("zone", "eu").
+			WithDetails(RetryInfo{RetryDelay: 30 * time.Second})
+	}))
+
+	w := request(s, "GET", "/quota", nil)
+	const want = `{"code":8,"msg":"quota exceeded","details":[{"retryDelay":"30s"}],"metadata":{"zone":"eu"}}`
+	if w.Code != 429 || w.Body.String() != want {
+		t.Errorf("%d %s", w.Code, w.Body.String())
+	}
+}
@@ -5,8 +5,9 @@ go 1.14
 require (
 	github.com/go-playground/locales v0.13.0
 	github.com/go-playground/universal-translator v0.17.0
-	github.com/go-playground/validator/v10 v10.2.0
-	github.com/json-iterator/go v1.1.9
+	github.com/go-playground/validator/v10 v10.3.0
+	github.com/json-iterator/go v1.1.12
 	github.com/pkg/errors v0.9.1
 	github.com/rs/zerolog v1.18.0
+	github.com/swaggo/files v1.0.1
 )
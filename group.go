@@ -1,5 +1,7 @@
 package fastapi
 
+import "strings"
+
 type Group struct {
 	server   *Server
 	prefix   string
@@ -17,19 +19,45 @@ func (g *Group) prepare(path string, handlers ...HandlerFunc) (p string, h []Han
 	return
 }
 
-func (g *Group) GET(path string, handlers ...HandlerFunc) {
+func (g *Group) Handle(method string, path string, handlers ...HandlerFunc) {
+	g.HandleWith(method, path, nil, handlers...)
+}
+
+func (g *Group) HandleWith(method string, path string, opt *RouteOption, handlers ...HandlerFunc) {
 	p, h := g.prepare(path, handlers...)
-	g.server.getRouter[p] = h
+	g.server.router.add(strings.ToUpper(method), p, opt, h)
+}
+
+func (g *Group) GET(path string, handlers ...HandlerFunc) {
+	g.Handle("GET", path, handlers...)
 }
 
 func (g *Group) POST(path string, handlers ...HandlerFunc) {
-	p, h := g.prepare(path, handlers...)
-	g.server.postRouter[p] = h
+	g.Handle("POST", path, handlers...)
+}
+
+func (g *Group) PUT(path string, handlers ...HandlerFunc) {
+	g.Handle("PUT", path, handlers...)
+}
+
+func (g *Group) PATCH(path string, handlers ...HandlerFunc) {
+	g.Handle("PATCH", path, handlers...)
+}
+
+func (g *Group) DELETE(path string, handlers ...HandlerFunc) {
+	g.Handle("DELETE", path, handlers...)
+}
+
+func (g *Group) HEAD(path string, handlers ...HandlerFunc) {
+	g.Handle("HEAD", path, handlers...)
+}
+
+func (g *Group) OPTIONS(path string, handlers ...HandlerFunc) {
+	g.Handle("OPTIONS", path, handlers...)
 }
 
 func (g *Group) ANY(path string, handlers ...HandlerFunc) {
-	p, h := g.prepare(path, handlers...)
-	g.server.anyRouter[p] = h
+	g.Handle(methodAny, path, handlers...)
 }
 
 func (g *Group) Group(prefix string, handlers ...HandlerFunc) *Group {
@@ -1,11 +1,8 @@
 package fastapi
 
 import (
-	"bytes"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"io"
-	"mime"
 	"os"
 	"strconv"
 	"strings"
@@ -31,6 +28,15 @@ func Logger() HandlerFunc {
 	return func(ctx *Context) {}
 }
 
+// LogErrors is an ErrorHandler logging every error reported during the request,
+// with the handler reporting it
+func LogErrors(ctx *Context, err error) error {
+	for _, item := range ctx.errors {
+		logger.Error().Str("handler", item.handler).Msgf("%s %s: %v", ctx.Request.Method, ctx.Request.URL.Path, item.err)
+	}
+	return err
+}
+
 type CorsOption struct {
 	AllowOrigin  string
 	AllowMethods []string
@@ -46,7 +52,7 @@ func CORS(opt *CorsOption) HandlerFunc {
 		opt.AllowOrigin = "*"
 	}
 	if len(opt.AllowMethods) == 0 {
-		opt.AllowMethods = []string{"GET", "POST"}
+		opt.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
 	}
 	if opt.MaxAge == 0 {
 		opt.MaxAge = 3600
@@ -68,33 +74,6 @@ func CORS(opt *CorsOption) HandlerFunc {
 	}
 }
 
-func bodyParser() HandlerFunc {
-	return func(ctx *Context) {
-		var body = []byte("")
-		contentType, _, err := mime.ParseMediaType(ctx.Request.Header.Get("Content-Type"))
-		if err != nil {
-			ctx.Storage.Set("body", body)
-			return
-		}
-
-		ctx.ContentType = contentType
-		if contentType == ContentType.Form {
-			err := ctx.Request.ParseForm()
-			if err == nil {
-				body = []byte(ctx.Request.Form.Encode())
-			}
-			ctx.Storage.Set("body", body)
-		} else {
-			var buf = bytes.NewBufferString("")
-			_, err := io.Copy(buf, ctx.Request.Body)
-			if err == nil {
-				body = buf.Bytes()
-			}
-			ctx.Storage.Set("body", body)
-		}
-	}
-}
-
 // limit concurrent access speed
 func Limit(n int64) HandlerFunc {
 	return func(ctx *Context) {
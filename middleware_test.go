@@ -0,0 +1,30 @@
+package fastapi
+
+import (
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	var s = New()
+	s.Use(CORS(nil))
+	s.ANY("/r", reply("ok"))
+
+	w := request(s, "OPTIONS", "/r", nil)
+	if w.Code != 204 || w.Body.Len() != 0 {
+		t.Errorf("preflight: %d %q", w.Code, w.Body.String())
+	}
+	if methods := w.Header().Get("Access-Control-Allow-Methods"); methods != "GET,POST,PUT,PATCH,DELETE,HEAD,OPTIONS" {
+		t.Errorf("Access-Control-Allow-Methods = %q", methods)
+	}
+	if w := request(s, "PUT", "/r", nil); w.Body.String() != "ok" || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("PUT: %q %v", w.Body.String(), w.Header())
+	}
+
+	s = New()
+	s.Use(CORS(&CorsOption{AllowOrigin: "https://example.com", AllowMethods: []string{"GET"}}))
+	s.GET("/r", reply("ok"))
+	w = request(s, "OPTIONS", "/r", nil)
+	if w.Header().Get("Access-Control-Allow-Methods") != "GET" || w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+		t.Errorf("custom options: %v", w.Header())
+	}
+}
@@ -0,0 +1,653 @@
+package fastapi
+
+import (
+	"bytes"
+	"github.com/json-iterator/go"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RouteOption describes a route for the OpenAPI document.
+// Request is the struct passed to Context.Bind, Response the struct passed to Context.JSON.
+// MaxBodyBytes overrides the server body size limit, -1 means no limit.
+// Stream routes read Request.Body themselves: it is neither limited nor buffered.
+type RouteOption struct {
+	Summary      string
+	Description  string
+	Tags         []string
+	Request      interface{}
+	Response     interface{}
+	MaxBodyBytes int64
+	Stream       bool
+
+	hidden  bool
+	handler string
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPI struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       OpenAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type Operation struct {
+	Summary     string                        `json:"summary,omitempty"`
+	Description string                        `json:"description,omitempty"`
+	Tags        []string                      `json:"tags,omitempty"`
+	Parameters  []*Parameter                  `json:"parameters,omitempty"`
+	RequestBody *RequestBody                  `json:"requestBody,omitempty"`
+	Responses   map[string]*OperationResponse `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type OperationResponse struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *uint64            `json:"minLength,omitempty"`
+	MaxLength            *uint64            `json:"maxLength,omitempty"`
+	MinItems             *uint64            `json:"minItems,omitempty"`
+	MaxItems             *uint64            `json:"maxItems,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	codeType = reflect.TypeOf(Code(0))
+)
+
+// OpenAPI generates an OpenAPI 3.0 document from the registered routes.
+// ANY routes are left out, since OpenAPI has no catch-all operation.
+func (s *Server) OpenAPI(info *OpenAPIInfo) *OpenAPI {
+	var meta = OpenAPIInfo{}
+	if info != nil {
+		meta = *info
+	}
+	if meta.Title == "" {
+		meta.Title = "FastAPI"
+	}
+	if meta.Version == "" {
+		meta.Version = "1.0.0"
+	}
+
+	var doc = &OpenAPI{
+		OpenAPI:    "3.0.3",
+		Info:       meta,
+		Paths:      make(map[string]map[string]*Operation),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+	var builder = &schemaBuilder{schemas: doc.Components.Schemas, types: make(map[string]reflect.Type)}
+	var errorSchema = builder.schemaOf(reflect.TypeOf(Error{}))
+	if s.codeEncoding == CodeSymbolic {
+		var names = make([]interface{}, 0, len(codeToStr))
+		for _, code := range sortedCodes() {
+			names = append(names, code.String())
+		}
+		doc.Components.Schemas["Error"].Properties["code"] = &Schema{Type: "string", Enum: names}
+	}
+
+	s.router.walk(func(pattern string, method string, handlers []HandlerFunc, opt *RouteOption) {
+		if method == methodAny || (opt != nil && opt.hidden) {
+			return
+		}
+		if opt == nil {
+			opt = &RouteOption{}
+		}
+
+		var op = &Operation{
+			Summary:     opt.Summary,
+			Description: opt.Description,
+			Tags:        opt.Tags,
+			Responses:   make(map[string]*OperationResponse),
+		}
+
+		var tagged = make([]*Parameter, 0)
+		if opt.Request != nil {
+			tagged = builder.sourceParameters(indirectType(reflect.TypeOf(opt.Request)))
+		}
+		for _, seg := range splitPath(pattern) {
+			if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+				var param = &Parameter{
+					Name:     seg[1:],
+					In:       "path",
+					Required: true,
+					Schema:   &Schema{Type: "string"},
+				}
+				for _, item := range tagged {
+					if item.In == "path" && item.Name == param.Name {
+						param.Schema = item.Schema
+					}
+				}
+				op.Parameters = append(op.Parameters, param)
+			}
+		}
+		for _, item := range tagged {
+			if item.In != "path" {
+				op.Parameters = append(op.Parameters, item)
+			}
+		}
+
+		if opt.Request != nil {
+			var typ = indirectType(reflect.TypeOf(opt.Request))
+			if hasBody(method) {
+				var schema = builder.schemaOf(typ)
+				op.RequestBody = &RequestBody{
+					Required: true,
+					Content: map[string]*MediaType{
+						ContentType.JSON: {Schema: schema},
+						ContentType.Form: {Schema: schema},
+					},
+				}
+				if hasFiles(typ) {
+					op.RequestBody.Content = map[string]*MediaType{ContentType.Multipart: {Schema: schema}}
+				}
+			} else if typ.Kind() == reflect.Struct {
+				op.Parameters = append(op.Parameters, builder.queryParameters(typ)...)
+			}
+		}
+
+		var ok = &OperationResponse{Description: "OK"}
+		if opt.Response != nil {
+			ok.Content = map[string]*MediaType{
+				ContentType.JSON: {Schema: builder.schemaOf(reflect.TypeOf(opt.Response))},
+			}
+		}
+		op.Responses["200"] = ok
+		op.Responses["default"] = &OperationResponse{
+			Description: "Error",
+			Content:     map[string]*MediaType{ContentType.JSON: {Schema: errorSchema}},
+		}
+
+		var path = openapiPath(pattern)
+		if _, exist := doc.Paths[path]; !exist {
+			doc.Paths[path] = make(map[string]*Operation)
+		}
+		doc.Paths[path][strings.ToLower(method)] = op
+	})
+
+	return doc
+}
+
+// ServeOpenAPI serves the generated document at path, as YAML when path ends
+// with .yaml or .yml and as JSON otherwise.
+func (s *Server) ServeOpenAPI(path string, info *OpenAPIInfo) {
+	var isYAML = strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+	s.HandleWith("GET", path, &RouteOption{hidden: true}, func(ctx *Context) {
+		var doc = s.OpenAPI(info)
+		if isYAML {
+			body, err := doc.YAML()
+			if err != nil {
+				ctx.Error(err)
+				return
+			}
+			ctx.Response.Header().Set("Content-Type", ContentType.YAML)
+			ctx.Write(200, body)
+			return
+		}
+		ctx.JSON(200, doc)
+	})
+}
+
+func (o *OpenAPI) JSON() ([]byte, error) {
+	return jsoniter.MarshalIndent(o, "", "  ")
+}
+
+func (o *OpenAPI) YAML() ([]byte, error) {
+	body, err := jsoniter.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := jsoniter.Unmarshal(body, &v); err != nil {
+		return nil, err
+	}
+	var buf = bytes.NewBufferString("")
+	writeYAML(buf, v, 0)
+	return buf.Bytes(), nil
+}
+
+var openapiParamRegexp = regexp.MustCompile(`/[:*]([^/]+)`)
+
+// openapiPath converts /users/:id to /users/{id}
+func openapiPath(pattern string) string {
+	return openapiParamRegexp.ReplaceAllString(pattern, "/{$1}")
+}
+
+func hasBody(method string) bool {
+	return method != "GET" && method != "HEAD" && method != "DELETE" && method != "OPTIONS"
+}
+
+// hasFiles reports whether a request struct has upload fields
+func hasFiles(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		var ft = typ.Field(i).Type
+		if ft == fileHeaderType || ft == fileHeadersType || (ft.Kind() == reflect.Struct && hasFiles(ft)) {
+			return true
+		}
+	}
+	return false
+}
+
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// fieldName returns the name used by Bind for a struct field, or "" to skip it
+func fieldName(field reflect.StructField) string {
+	if field.Name[0] >= 'a' && field.Name[0] <= 'z' {
+		return ""
+	}
+	var tag = strings.Split(field.Tag.Get("json"), ",")[0]
+	if tag == "-" {
+		return ""
+	}
+	if tag == "" {
+		return field.Name
+	}
+	return tag
+}
+
+type schemaBuilder struct {
+	schemas map[string]*Schema
+	types   map[string]reflect.Type
+}
+
+func (b *schemaBuilder) schemaOf(typ reflect.Type) *Schema {
+	if typ == fileHeaderType {
+		return &Schema{Type: "string", Format: "binary"}
+	}
+	typ = indirectType(typ)
+	if typ == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	if typ == durationType {
+		return &Schema{Type: "string", Format: "duration"}
+	}
+	if typ == codeType {
+		return &Schema{Type: "integer", Format: "int32"}
+	}
+	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaOf(typ.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(typ.Elem())}
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return b.structSchema(typ)
+		}
+		var name = typ.Name()
+		if exist, ok := b.types[name]; ok && exist != typ {
+			name = strings.Replace(typ.String(), ".", "_", -1)
+		}
+		if _, ok := b.types[name]; !ok {
+			b.types[name] = typ
+			b.schemas[name] = &Schema{}
+			*b.schemas[name] = *b.structSchema(typ)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (b *schemaBuilder) structSchema(typ reflect.Type) *Schema {
+	var schema = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.eachField(typ, func(name string, field reflect.StructField) {
+		var item = b.schemaOf(field.Type)
+		if item.Ref == "" {
+			applyTags(item, field)
+		}
+		schema.Properties[name] = item
+		if isRequired(field) {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+	return schema
+}
+
+// queryParameters flattens the fields of a struct into query parameters, the
+// way bindForm reads them. Slices are read from name[]. A struct pointer back
+// to an enclosing type is skipped.
+func (b *schemaBuilder) queryParameters(typ reflect.Type) []*Parameter {
+	var params = make([]*Parameter, 0)
+	var path = make(map[reflect.Type]bool)
+	var walk func(typ reflect.Type)
+	walk = func(typ reflect.Type) {
+		path[typ] = true
+		defer delete(path, typ)
+
+		for i := 0; i < typ.NumField(); i++ {
+			var field = typ.Field(i)
+			var name = fieldName(field)
+			if name == "" {
+				continue
+			}
+			if in, _ := sourceTag(field); in != "" {
+				continue
+			}
+			var ft = indirectType(field.Type)
+			if ft.Kind() == reflect.Struct && !isScalar(ft) {
+				if !path[ft] {
+					walk(ft)
+				}
+				continue
+			}
+			if ft.Kind() == reflect.Slice {
+				name += "[]"
+			}
+			var schema = b.schemaOf(ft)
+			applyTags(schema, field)
+			params = append(params, &Parameter{
+				Name:     name,
+				In:       "query",
+				Required: isRequired(field),
+				Schema:   schema,
+			})
+		}
+	}
+	walk(typ)
+	return params
+}
+
+// sourceParameters returns the parameters of the fields tagged path, header, cookie or query.
+// A struct pointer back to an enclosing type is skipped.
+func (b *schemaBuilder) sourceParameters(typ reflect.Type) []*Parameter {
+	var params = make([]*Parameter, 0)
+	if typ.Kind() != reflect.Struct {
+		return params
+	}
+	var path = make(map[reflect.Type]bool)
+	var walk func(typ reflect.Type)
+	walk = func(typ reflect.Type) {
+		path[typ] = true
+		defer delete(path, typ)
+
+		for i := 0; i < typ.NumField(); i++ {
+			var field = typ.Field(i)
+			if field.Name[0] >= 'a' && field.Name[0] <= 'z' {
+				continue
+			}
+			var ft = indirectType(field.Type)
+			if ft.Kind() == reflect.Struct && !isScalar(ft) {
+				if !path[ft] {
+					walk(ft)
+				}
+				continue
+			}
+			in, name := sourceTag(field)
+			if in == "" {
+				continue
+			}
+			var schema = b.schemaOf(ft)
+			applyTags(schema, field)
+			params = append(params, &Parameter{
+				Name:     name,
+				In:       in,
+				Required: in == "path" || isRequired(field),
+				Schema:   schema,
+			})
+		}
+	}
+	walk(typ)
+	return params
+}
+
+// sourceTag returns the source and name of a field tagged path, header, cookie or query
+func sourceTag(field reflect.StructField) (in string, name string) {
+	for _, source := range []string{"path", "header", "cookie", "query"} {
+		if name = field.Tag.Get(source); name != "" {
+			return source, name
+		}
+	}
+	return "", ""
+}
+
+// eachField walks the documented body fields of a struct, flattening embedded structs.
+// An embedded pointer back to an enclosing type is skipped.
+func (b *schemaBuilder) eachField(typ reflect.Type, fn func(name string, field reflect.StructField)) {
+	b.eachEmbedded(typ, make(map[reflect.Type]bool), fn)
+}
+
+func (b *schemaBuilder) eachEmbedded(typ reflect.Type, path map[reflect.Type]bool, fn func(name string, field reflect.StructField)) {
+	path[typ] = true
+	defer delete(path, typ)
+
+	for i := 0; i < typ.NumField(); i++ {
+		var field = typ.Field(i)
+		if in, _ := sourceTag(field); in != "" {
+			continue
+		}
+		if field.Anonymous && field.Tag.Get("json") == "" && indirectType(field.Type).Kind() == reflect.Struct {
+			if !path[indirectType(field.Type)] {
+				b.eachEmbedded(indirectType(field.Type), path, fn)
+			}
+			continue
+		}
+		if name := fieldName(field); name != "" {
+			fn(name, field)
+		}
+	}
+}
+
+func isRequired(field reflect.StructField) bool {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyTags copies the default tag and the validate rules understood by
+// OpenAPI into the schema
+func applyTags(schema *Schema, field reflect.StructField) {
+	if val := field.Tag.Get("default"); val != "" {
+		switch schema.Type {
+		case "boolean":
+			schema.Default = val == "true"
+		case "integer":
+			schema.Default = ToInt(val)
+		case "number":
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				schema.Default = f
+			}
+		case "string":
+			schema.Default = val
+		}
+	}
+
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		var key, param = rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			key, param = rule[:i], rule[i+1:]
+		}
+
+		switch key {
+		case "dive":
+			return
+		case "min", "gte":
+			setBound(schema, param, true)
+		case "max", "lte":
+			setBound(schema, param, false)
+		case "len":
+			setBound(schema, param, true)
+			setBound(schema, param, false)
+		case "oneof":
+			for _, item := range strings.Fields(param) {
+				if schema.Type == "integer" {
+					schema.Enum = append(schema.Enum, ToInt(item))
+				} else {
+					schema.Enum = append(schema.Enum, item)
+				}
+			}
+		case "email":
+			schema.Format = "email"
+		case "url", "uri":
+			schema.Format = "uri"
+		case "uuid", "uuid4":
+			schema.Format = "uuid"
+		case "ip", "ipv4":
+			schema.Format = "ipv4"
+		case "ipv6":
+			schema.Format = "ipv6"
+		}
+	}
+}
+
+func setBound(schema *Schema, param string, lower bool) {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	var n = uint64(f)
+	switch schema.Type {
+	case "integer", "number":
+		if lower {
+			schema.Minimum = &f
+		} else {
+			schema.Maximum = &f
+		}
+	case "string":
+		if lower {
+			schema.MinLength = &n
+		} else {
+			schema.MaxLength = &n
+		}
+	case "array":
+		if lower {
+			schema.MinItems = &n
+		} else {
+			schema.MaxItems = &n
+		}
+	}
+}
+
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// writeYAML encodes a decoded JSON value as block style YAML
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int) {
+	var pad = strings.Repeat(" ", indent)
+	switch val := v.(type) {
+	case map[string]interface{}:
+		var keys = make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			var key = k
+			if !yamlPlainKey.MatchString(k) {
+				key = yamlScalar(k)
+			}
+			buf.WriteString(pad + key + ":")
+			writeYAMLValue(buf, val[k], indent)
+		}
+	case []interface{}:
+		for _, item := range val {
+			buf.WriteString(pad + "-")
+			writeYAMLValue(buf, item, indent)
+		}
+	}
+}
+
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, val, indent+2)
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, val, indent+2)
+	default:
+		buf.WriteString(" " + yamlScalar(val) + "\n")
+	}
+}
+
+func yamlScalar(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case string:
+		body, _ := jsoniter.Marshal(val)
+		return string(body)
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package fastapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type openapiUser struct {
+	Name  string `json:"name" validate:"required,min=2"`
+	Age   int    `json:"age" default:"18"`
+	Roles []string
+}
+
+type openapiQuery struct {
+	Page int    `json:"page" validate:"min=1"`
+	Tags []int  `json:"tags"`
+	Sort string `json:"sort" validate:"oneof=asc desc"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	var s = New()
+	s.HandleWith("POST", "/users", &RouteOption{Summary: "create", Request: &openapiUser{}, Response: &openapiUser{}}, noop)
+	s.HandleWith("GET", "/users/:id", &RouteOption{Response: &openapiUser{}}, noop)
+	s.HandleWith("GET", "/users", &RouteOption{Request: &openapiQuery{}}, noop)
+	s.HandleWith("GET", "/hidden", &RouteOption{hidden: true}, noop)
+	s.ANY("/any", noop)
+
+	var doc = s.OpenAPI(&OpenAPIInfo{Title: "test"})
+	if doc.Info.Title != "test" || doc.Info.Version != "1.0.0" {
+		t.Errorf("info %+v", doc.Info)
+	}
+	if _, ok := doc.Paths["/hidden"]; ok {
+		t.Error("hidden route documented")
+	}
+	if _, ok := doc.Paths["/any"]; ok {
+		t.Error("ANY route documented")
+	}
+
+	var create = doc.Paths["/users"]["post"]
+	if create == nil || create.Summary != "create" || create.RequestBody == nil {
+		t.Fatalf("POST /users %+v", create)
+	}
+	var user = doc.Components.Schemas["openapiUser"]
+	if user == nil || user.Properties["name"].MinLength == nil || user.Properties["age"].Default == nil {
+		t.Fatalf("openapiUser schema %+v", user)
+	}
+	if len(user.Required) != 1 || user.Required[0] != "name" {
+		t.Errorf("required %v", user.Required)
+	}
+
+	var get = doc.Paths["/users/{id}"]["get"]
+	if get == nil || len(get.Parameters) != 1 || get.Parameters[0].In != "path" || get.Parameters[0].Name != "id" {
+		t.Fatalf("GET /users/{id} %+v", get)
+	}
+
+	var names []string
+	for _, p := range doc.Paths["/users"]["get"].Parameters {
+		names = append(names, p.In+":"+p.Name)
+	}
+	if got := strings.Join(names, ","); got != "query:page,query:tags[],query:sort" {
+		t.Errorf("query parameters %s", got)
+	}
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	var s = New()
+	s.HandleWith("POST", "/users", &RouteOption{Request: &openapiUser{}}, noop)
+	s.ServeOpenAPI("/openapi.json", nil)
+	s.ServeOpenAPI("/openapi.yaml", nil)
+
+	w := request(s, "GET", "/openapi.json", nil)
+	var doc OpenAPI
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || w.Code != 200 {
+		t.Fatalf("openapi.json: %d %v", w.Code, err)
+	}
+	if _, ok := doc.Paths["/openapi.json"]; ok {
+		t.Error("the document route is documented")
+	}
+	if doc.Paths["/users"]["post"] == nil {
+		t.Error("POST /users is missing")
+	}
+
+	w = request(s, "GET", "/openapi.yaml", nil)
+	if w.Code != 200 || w.Header().Get("Content-Type") != ContentType.YAML || !strings.Contains(w.Body.String(), `openapi: "3.0.3"`) {
+		t.Errorf("openapi.yaml: %d %s", w.Code, w.Body.String())
+	}
+}
+
+// openapiNode refers to itself, through a pointer and an embedded pointer
+type openapiNode struct {
+	*openapiNode
+	Name string       `json:"name"`
+	Next *openapiNode `json:"next"`
+}
+
+func TestOpenAPIRecursive(t *testing.T) {
+	var s = New()
+	s.HandleWith("GET", "/nodes", &RouteOption{Request: &openapiNode{}}, noop)
+	s.HandleWith("POST", "/nodes", &RouteOption{Request: &openapiNode{}, Response: &openapiNode{}}, noop)
+
+	var doc = s.OpenAPI(nil)
+	if len(doc.Paths["/nodes"]["get"].Parameters) != 1 {
+		t.Errorf("GET /nodes parameters %+v", doc.Paths["/nodes"]["get"].Parameters)
+	}
+	var node = doc.Components.Schemas["openapiNode"]
+	if node == nil || node.Properties["next"].Ref != "#/components/schemas/openapiNode" {
+		t.Errorf("openapiNode schema %+v", node)
+	}
+}
+
+func TestOpenAPISources(t *testing.T) {
+	var s = New()
+	s.HandleWith("POST", "/users/:id", &RouteOption{Request: &sourceForm{}}, noop)
+
+	var names []string
+	for _, p := range s.OpenAPI(nil).Paths["/users/{id}"]["post"].Parameters {
+		names = append(names, p.In+":"+p.Name)
+	}
+	if got := strings.Join(names, ","); got != "path:id,header:x-tenant,cookie:session,query:page,query:tag" {
+		t.Errorf("parameters %s", got)
+	}
+}
@@ -0,0 +1,106 @@
+package fastapi
+
+import (
+	"github.com/json-iterator/go"
+	"net/http"
+	"strings"
+)
+
+// ErrorRenderer writes the error response of err with status.
+// errs lists the failed fields when err comes from validation.
+type ErrorRenderer func(ctx *Context, status int, err *Error, errs ValidationErrors)
+
+// JSONRenderer is the default renderer, it writes
+// {"code":3,"msg":"...","details":[...],"metadata":{...}}.
+// Validation errors are written as the details.
+var JSONRenderer ErrorRenderer = func(ctx *Context, status int, err *Error, errs ValidationErrors) {
+	var body = &errorBody{Code: ctx.errorCode(err.Code), Msg: err.Msg, Details: err.Details, Metadata: err.Metadata}
+	for _, item := range errs {
+		body.Details = append(body.Details, item)
+	}
+	content, e := jsoniter.Marshal(body)
+	if e != nil {
+		renderFallback(ctx)
+		return
+	}
+	ctx.Response.Header().Set("Content-Type", ContentType.JSON)
+	ctx.Write(status, content)
+}
+
+type errorBody struct {
+	Code     interface{}       `json:"code"`
+	Msg      string            `json:"msg"`
+	Details  []interface{}     `json:"details,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// ProblemRenderer returns a renderer writing RFC 7807 application/problem+json.
+// The type is typeBase followed by the code name, e.g. https://example.com/errors/not-found,
+// or about:blank when typeBase is empty. Code, validation errors, details and metadata
+// are extension members.
+func ProblemRenderer(typeBase string) ErrorRenderer {
+	typeBase = strings.TrimSuffix(typeBase, "/")
+	return func(ctx *Context, status int, err *Error, errs ValidationErrors) {
+		var body = &problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   err.Msg,
+			Instance: ctx.Request.URL.Path,
+			Code:     ctx.errorCode(err.Code),
+			Errors:   errs,
+			Details:  err.Details,
+			Metadata: err.Metadata,
+		}
+		if typeBase != "" {
+			body.Type = typeBase + "/" + strings.ToLower(strings.Replace(err.Code.String(), "_", "-", -1))
+		}
+
+		content, e := jsoniter.Marshal(body)
+		if e != nil {
+			renderFallback(ctx)
+			return
+		}
+		ctx.Response.Header().Set("Content-Type", ContentType.Problem)
+		ctx.Write(status, content)
+	}
+}
+
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     interface{}       `json:"code"`
+	Errors   ValidationErrors  `json:"errors,omitempty"`
+	Details  []interface{}     `json:"details,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// renderFallback answers a plain 500 when the error body does not marshal,
+// e.g. for details of an unsupported type
+func renderFallback(ctx *Context) {
+	ctx.Response.Header().Set("Content-Type", ContentType.Text)
+	ctx.Write(500, []byte(http.StatusText(500)))
+}
+
+// renderError writes err with the renderer of the server, unless a response has been written
+func (c *Context) renderError(status int, err *Error, errs ValidationErrors) {
+	if c.Written() {
+		return
+	}
+	var render = JSONRenderer
+	if c.server != nil && c.server.renderError != nil {
+		render = c.server.renderError
+	}
+	render(c, status, err, errs)
+}
+
+// errorCode encodes code as the server is set to
+func (c *Context) errorCode(code Code) interface{} {
+	if c.server != nil && c.server.codeEncoding == CodeSymbolic {
+		return code.String()
+	}
+	return code
+}
@@ -0,0 +1,73 @@
+package fastapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type badDetail struct{}
+
+func (badDetail) MarshalJSON() ([]byte, error) {
+	return nil, json.Unmarshal([]byte("x"), &struct{}{})
+}
+
+func TestProblemRenderer(t *testing.T) {
+	var s = New()
+	s.SetErrorRenderer(ProblemRenderer("https://example.com/errors/"))
+	s.GET("/users/:id", HandlerE(func(ctx *Context) error {
+		return NewError(NotFound, "user not found").WithMetadata("id", ctx.Param("id"))
+	}))
+	s.GET("/signup", HandlerE(func(ctx *Context) error {
+		var form struct {
+			Name string `json:"name" validate:"required"`
+		}
+		return ctx.Bind(&form)
+	}))
+
+	w := request(s, "GET", "/users/7", nil)
+	const want = `{"type":"https://example.com/errors/not-found","title":"Not Found","status":404,` +
+		`"detail":"user not found","instance":"/users/7","code":5,"metadata":{"id":"7"}}`
+	if w.Code != 404 || w.Body.String() != want || w.Header().Get("Content-Type") != ContentType.Problem {
+		t.Errorf("%d %s %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = request(s, "GET", "/signup", nil)
+	if w.Code != 400 || !strings.Contains(w.Body.String(), `"errors":[{"field":"name","rule":"required"`) {
+		t.Errorf("validation: %d %s", w.Code, w.Body.String())
+	}
+
+	// NoRoute and NoMethod use the renderer too
+	s.SetCodeEncoding(CodeSymbolic)
+	w = request(s, "GET", "/none", nil)
+	if w.Code != 404 || !strings.Contains(w.Body.String(), `"type":"https://example.com/errors/not-found"`) ||
+		!strings.Contains(w.Body.String(), `"code":"NOT_FOUND"`) {
+		t.Errorf("no route: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestProblemRendererBlank(t *testing.T) {
+	var s = New()
+	s.SetErrorRenderer(ProblemRenderer(""))
+	if w := request(s, "DELETE", "/none", nil); !strings.HasPrefix(w.Body.String(), `{"type":"about:blank","title":"Not Found"`) {
+		t.Errorf("%s", w.Body.String())
+	}
+}
+
+func TestRenderFallback(t *testing.T) {
+	var handler = HandlerE(func(ctx *Context) error {
+		return NewError(Internal, "internal error").WithDetails(badDetail{})
+	})
+	for name, renderer := range map[string]ErrorRenderer{
+		"json":    JSONRenderer,
+		"problem": ProblemRenderer(""),
+	} {
+		var s = New()
+		s.SetErrorRenderer(renderer)
+		s.GET("/r", handler)
+		w := request(s, "GET", "/r", nil)
+		if w.Code != 500 || w.Body.String() != "Internal Server Error" || w.Header().Get("Content-Type") != ContentType.Text {
+			t.Errorf("%s: %d %q %v", name, w.Code, w.Body.String(), w.Header())
+		}
+	}
+}
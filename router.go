@@ -0,0 +1,235 @@
+package fastapi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const methodAny = "ANY"
+
+type Param struct {
+	Key   string
+	Value string
+}
+
+type Params []Param
+
+func (ps Params) Get(key string) (string, bool) {
+	for _, p := range ps {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// node is a segment of the route tree. static children are matched first,
+// then the named parameter child (:name), then the catch-all child (*name).
+type node struct {
+	name     string
+	pattern  string
+	children map[string]*node
+	param    *node
+	wildcard *node
+	handlers map[string][]HandlerFunc
+	chains   map[string][]HandlerFunc
+	options  map[string]*RouteOption
+}
+
+func newNode() *node {
+	return &node{children: make(map[string]*node)}
+}
+
+type router struct {
+	root   *node
+	global []HandlerFunc
+}
+
+func newRouter() *router {
+	return &router{root: newNode()}
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(strings.TrimSpace(path), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func (r *router) add(method string, path string, opt *RouteOption, handlers []HandlerFunc) {
+	var n = r.root
+	var segments = splitPath(path)
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			name := seg[1:]
+			if name == "" {
+				panic(fmt.Sprintf("fastapi: empty param name in path %s", path))
+			}
+			if n.param == nil {
+				n.param = newNode()
+				n.param.name = name
+			} else if n.param.name != name {
+				panic(fmt.Sprintf("fastapi: param :%s conflicts with :%s in path %s", name, n.param.name, path))
+			}
+			n = n.param
+		case strings.HasPrefix(seg, "*"):
+			name := seg[1:]
+			if name == "" {
+				panic(fmt.Sprintf("fastapi: empty catch-all name in path %s", path))
+			}
+			if i != len(segments)-1 {
+				panic(fmt.Sprintf("fastapi: catch-all *%s must be the last segment in path %s", name, path))
+			}
+			if n.wildcard == nil {
+				n.wildcard = newNode()
+				n.wildcard.name = name
+			} else if n.wildcard.name != name {
+				panic(fmt.Sprintf("fastapi: catch-all *%s conflicts with *%s in path %s", name, n.wildcard.name, path))
+			}
+			n = n.wildcard
+		default:
+			child, ok := n.children[seg]
+			if !ok {
+				child = newNode()
+				n.children[seg] = child
+			}
+			n = child
+		}
+	}
+
+	if n.handlers == nil {
+		n.handlers = make(map[string][]HandlerFunc)
+		n.chains = make(map[string][]HandlerFunc)
+		n.options = make(map[string]*RouteOption)
+	}
+	n.pattern = "/" + strings.Join(segments, "/")
+	n.handlers[method] = handlers
+	n.options[method] = opt
+	n.chains[method] = combine(r.global, handlers)
+}
+
+// compile rebuilds the chain of every route with new global middleware
+func (r *router) compile(global []HandlerFunc) {
+	r.global = global
+	r.root.compile(global)
+}
+
+func (n *node) compile(global []HandlerFunc) {
+	for method, handlers := range n.handlers {
+		n.chains[method] = combine(global, handlers)
+	}
+	for _, child := range n.children {
+		child.compile(global)
+	}
+	if n.param != nil {
+		n.param.compile(global)
+	}
+	if n.wildcard != nil {
+		n.wildcard.compile(global)
+	}
+}
+
+// combine returns the chain of the global handlers followed by handlers.
+// It panics if the chain is too long for Abort.
+func combine(global []HandlerFunc, handlers []HandlerFunc) []HandlerFunc {
+	if len(global)+len(handlers) >= abortIndex {
+		panic(fmt.Sprintf("fastapi: a chain has at most %d handlers", abortIndex-1))
+	}
+	var h = make([]HandlerFunc, 0, len(global)+len(handlers))
+	h = append(h, global...)
+	h = append(h, handlers...)
+	return h
+}
+
+// find returns the node registered for path, collecting path params on the way.
+func (r *router) find(path string) (*node, Params) {
+	var params = make(Params, 0)
+	n := r.root.match(splitPath(path), &params)
+	return n, params
+}
+
+func (n *node) match(segments []string, params *Params) *node {
+	if len(segments) == 0 {
+		if n.handlers != nil {
+			return n
+		}
+		if n.wildcard != nil && n.wildcard.handlers != nil {
+			*params = append(*params, Param{Key: n.wildcard.name, Value: ""})
+			return n.wildcard
+		}
+		return nil
+	}
+
+	var seg = segments[0]
+	if child, ok := n.children[seg]; ok {
+		if result := child.match(segments[1:], params); result != nil {
+			return result
+		}
+	}
+
+	if n.param != nil {
+		var size = len(*params)
+		*params = append(*params, Param{Key: n.param.name, Value: seg})
+		if result := n.param.match(segments[1:], params); result != nil {
+			return result
+		}
+		*params = (*params)[:size]
+	}
+
+	if n.wildcard != nil && n.wildcard.handlers != nil {
+		*params = append(*params, Param{Key: n.wildcard.name, Value: strings.Join(segments, "/")})
+		return n.wildcard
+	}
+	return nil
+}
+
+// lookup returns the compiled chain and the option of the node for the
+// method. HEAD falls back to GET, and every method falls back to ANY.
+func (n *node) lookup(method string) ([]HandlerFunc, *RouteOption, bool) {
+	if _, ok := n.chains[method]; !ok {
+		if _, ok := n.chains["GET"]; ok && method == "HEAD" {
+			method = "GET"
+		} else {
+			method = methodAny
+		}
+	}
+	chain, ok := n.chains[method]
+	return chain, n.options[method], ok
+}
+
+// allowed returns the sorted methods registered on the node, for the Allow header.
+func (n *node) allowed() []string {
+	var methods = make([]string, 0, len(n.handlers)+1)
+	for method := range n.handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := n.handlers["GET"]; ok {
+		if _, ok := n.handlers["HEAD"]; !ok {
+			methods = append(methods, "HEAD")
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func (r *router) walk(fn func(pattern string, method string, handlers []HandlerFunc, opt *RouteOption)) {
+	r.root.walk(fn)
+}
+
+func (n *node) walk(fn func(pattern string, method string, handlers []HandlerFunc, opt *RouteOption)) {
+	for method, handlers := range n.handlers {
+		fn(n.pattern, method, handlers, n.options[method])
+	}
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+	if n.param != nil {
+		n.param.walk(fn)
+	}
+	if n.wildcard != nil {
+		n.wildcard.walk(fn)
+	}
+}
@@ -0,0 +1,122 @@
+package fastapi
+
+import (
+	"testing"
+)
+
+func noop(ctx *Context) {}
+
+func TestRouterFind(t *testing.T) {
+	var r = newRouter()
+	for _, path := range []string{
+		"/",
+		"/users",
+		"/users/me",
+		"/users/:id",
+		"/users/:id/posts/:post",
+		"/static/*filepath",
+	} {
+		r.add("GET", path, nil, []HandlerFunc{noop})
+	}
+
+	var cases = []struct {
+		path    string
+		pattern string
+		params  Params
+	}{
+		{"/", "/", Params{}},
+		{"/users", "/users", Params{}},
+		{"/users/", "/users", Params{}},
+		{"/users/me", "/users/me", Params{}},
+		{"/users/42", "/users/:id", Params{{"id", "42"}}},
+		{"/users/42/posts/7", "/users/:id/posts/:post", Params{{"id", "42"}, {"post", "7"}}},
+		{"/static/css/app.css", "/static/*filepath", Params{{"filepath", "css/app.css"}}},
+		{"/static", "/static/*filepath", Params{{"filepath", ""}}},
+	}
+	for _, item := range cases {
+		n, params := r.find(item.path)
+		if n == nil {
+			t.Errorf("%s: no route", item.path)
+			continue
+		}
+		if n.pattern != item.pattern {
+			t.Errorf("%s: matched %s, want %s", item.path, n.pattern, item.pattern)
+		}
+		if len(params) != len(item.params) {
+			t.Errorf("%s: params %v, want %v", item.path, params, item.params)
+			continue
+		}
+		for i := range params {
+			if params[i] != item.params[i] {
+				t.Errorf("%s: params %v, want %v", item.path, params, item.params)
+			}
+		}
+	}
+
+	for _, path := range []string{"/posts", "/users/42/posts", "/users/42/comments/1"} {
+		if n, _ := r.find(path); n != nil {
+			t.Errorf("%s: matched %s, want no route", path, n.pattern)
+		}
+	}
+}
+
+func TestRouterBacktrack(t *testing.T) {
+	var r = newRouter()
+	r.add("GET", "/a/b/c", nil, []HandlerFunc{noop})
+	r.add("GET", "/a/:x/d", nil, []HandlerFunc{noop})
+
+	n, params := r.find("/a/b/d")
+	if n == nil || n.pattern != "/a/:x/d" {
+		t.Fatalf("matched %v, want /a/:x/d", n)
+	}
+	if v, _ := params.Get("x"); v != "b" {
+		t.Errorf("x = %q, want b", v)
+	}
+}
+
+func TestRouterConflict(t *testing.T) {
+	var cases = [][2]string{
+		{"/users/:id", "/users/:name"},
+		{"/files/*path", "/files/*name"},
+		{"/files/*path/edit", ""},
+		{"/users/:", ""},
+	}
+	for _, item := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%v: want a panic", item)
+				}
+			}()
+			var r = newRouter()
+			for _, path := range item {
+				if path != "" {
+					r.add("GET", path, nil, []HandlerFunc{noop})
+				}
+			}
+		}()
+	}
+}
+
+func TestParamsGet(t *testing.T) {
+	var params = Params{{"id", "1"}, {"name", "x"}}
+	if v, ok := params.Get("name"); !ok || v != "x" {
+		t.Errorf("Get(name) = %q, %v", v, ok)
+	}
+	if _, ok := params.Get("none"); ok {
+		t.Error("Get(none) found a value")
+	}
+}
+
+func TestCombineLimit(t *testing.T) {
+	var handlers = make([]HandlerFunc, abortIndex-1)
+	if chain := combine(nil, handlers); len(chain) != abortIndex-1 {
+		t.Errorf("chain of %d handlers", len(chain))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("a chain reaching abortIndex does not panic")
+		}
+	}()
+	combine([]HandlerFunc{noop}, handlers)
+}
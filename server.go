@@ -2,6 +2,7 @@ package fastapi
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"reflect"
 	"runtime"
@@ -24,30 +25,122 @@ const (
 	ProductMode
 )
 
+// CodeEncoding is how error responses encode Code
+type CodeEncoding uint8
+
+const (
+	// CodeNumeric writes codes as numbers, e.g. "code":5
+	CodeNumeric CodeEncoding = iota
+	// CodeSymbolic writes codes as names, e.g. "code":"NOT_FOUND"
+	CodeSymbolic
+)
+
 type Server struct {
-	handlers   []HandlerFunc
-	getRouter  map[string][]HandlerFunc
-	postRouter map[string][]HandlerFunc
-	anyRouter  map[string][]HandlerFunc
-	catch      func(ctx *Context, err interface{})
+	handlers      []HandlerFunc
+	router        *router
+	noRoute       []HandlerFunc
+	noMethod      []HandlerFunc
+	noRouteChain  []HandlerFunc
+	noMethodChain []HandlerFunc
+	maxBodyBytes  int64
+	catch         func(ctx *Context, err interface{})
+	statusOf      func(code Code) int
+	codeEncoding  CodeEncoding
+	renderError   ErrorRenderer
+	onError       []ErrorHandler
 }
 
 func New() *Server {
 	s := &Server{
-		handlers:   make([]HandlerFunc, 0),
-		getRouter:  make(map[string][]HandlerFunc),
-		postRouter: make(map[string][]HandlerFunc),
-		anyRouter:  make(map[string][]HandlerFunc),
+		handlers: make([]HandlerFunc, 0),
+		router:   newRouter(),
+		noRoute:  []HandlerFunc{defaultNoRoute},
+		noMethod: []HandlerFunc{defaultNoMethod},
+		catch:    defaultCatcher,
 	}
-	s.Use(bodyParser())
+	s.noRouteChain = combine(s.handlers, s.noRoute)
+	s.noMethodChain = combine(s.handlers, s.noMethod)
 	return s
 }
 
+// SetMaxBodyBytes limits the size of request bodies, 0 means no limit.
+// Reading a larger body answers 413. RouteOption.MaxBodyBytes overrides it per route.
+func (s *Server) SetMaxBodyBytes(n int64) {
+	s.maxBodyBytes = n
+}
+
+// SetHTTPStatus replaces Code.HTTPStatus as the mapping of error codes to the
+// http status written by the default catcher, e.g. to answer 200 for every code.
+func (s *Server) SetHTTPStatus(fn func(code Code) int) {
+	s.statusOf = fn
+}
+
+// SetCodeEncoding sets how the error responses of the server encode Code, numbers by default
+func (s *Server) SetCodeEncoding(e CodeEncoding) {
+	s.codeEncoding = e
+}
+
+// SetErrorRenderer sets how the default catcher, NoRoute and NoMethod write errors,
+// e.g. ProblemRenderer(""). Defaults to JSONRenderer.
+func (s *Server) SetErrorRenderer(fn ErrorRenderer) {
+	s.renderError = fn
+}
+
+// OnError appends handlers to the error chain, which runs after the handlers of a
+// request that reported errors with ctx.Error or returned one with HandlerE.
+// Each gets the error left by the previous one, to log or map it, and the
+// catch function renders what the last one returns. Returning nil ends the chain,
+// e.g. after writing the response. The chain also runs for requests whose
+// response was already written, check ctx.Written before writing; catch does not.
+func (s *Server) OnError(handlers ...ErrorHandler) {
+	s.onError = append(s.onError, handlers...)
+}
+
+// handleError runs the error chain on err, then the catch function
+// unless the handlers have already written the response
+func (s *Server) handleError(ctx *Context, err error) {
+	for _, h := range s.onError {
+		if err = h(ctx, err); err == nil {
+			return
+		}
+	}
+	if !ctx.Written() {
+		s.catch(ctx, err)
+	}
+}
+
+// SetCatch sets the function rendering errors at the end of the error chain,
+// and the panics of handlers
 func (s *Server) SetCatch(fn func(ctx *Context, err interface{})) {
 	s.catch = fn
 }
 
-// global middleware
+// NoRoute sets the handlers for requests whose path matches no route.
+// They run after the global middleware.
+func (s *Server) NoRoute(handles ...HandlerFunc) {
+	s.noRoute = s.prepare(handles...)
+	s.noRouteChain = combine(s.handlers, s.noRoute)
+}
+
+// notFound replaces the rest of the chain with the NoRoute handlers, for handlers
+// answering as if their path did not exist. The global middleware has already run.
+func (s *Server) notFound(ctx *Context) {
+	ctx.handlers = s.noRoute
+	ctx.index = -1
+	ctx.Next()
+}
+
+// NoMethod sets the handlers for requests whose path matches a route but
+// whose method does not. The Allow header is set before they run.
+func (s *Server) NoMethod(handles ...HandlerFunc) {
+	s.noMethod = s.prepare(handles...)
+	s.noMethodChain = combine(s.handlers, s.noMethod)
+}
+
+// global middleware. Every route is compiled into a single chain of
+// global + group + route handlers; calling Use after routes have been
+// registered recompiles them, so global middleware always runs first.
+// Use must not be called once the server is serving requests.
 func (s *Server) Use(handles ...HandlerFunc) {
 	for _, handle := range handles {
 		name := runtime.FuncForPC(reflect.ValueOf(handle).Pointer()).Name()
@@ -55,6 +148,9 @@ func (s *Server) Use(handles ...HandlerFunc) {
 			s.handlers = append(s.handlers, handle)
 		}
 	}
+	s.router.compile(s.handlers)
+	s.noRouteChain = combine(s.handlers, s.noRoute)
+	s.noMethodChain = combine(s.handlers, s.noMethod)
 }
 
 func (s *Server) prepare(handlers ...HandlerFunc) []HandlerFunc {
@@ -75,16 +171,49 @@ func (s *Server) Group(prefix string, handlers ...HandlerFunc) *Group {
 	}
 }
 
+// Handle registers handlers for the given http method and path
+func (s *Server) Handle(method string, path string, handles ...HandlerFunc) {
+	s.HandleWith(method, path, nil, handles...)
+}
+
+// HandleWith registers handlers like Handle, with documentation options
+// used by the OpenAPI generator
+func (s *Server) HandleWith(method string, path string, opt *RouteOption, handles ...HandlerFunc) {
+	s.router.add(strings.ToUpper(method), path, opt, s.prepare(handles...))
+}
+
 func (s *Server) GET(path string, handles ...HandlerFunc) {
-	s.getRouter[path] = s.prepare(handles...)
+	s.Handle("GET", path, handles...)
 }
 
 func (s *Server) POST(path string, handles ...HandlerFunc) {
-	s.postRouter[path] = s.prepare(handles...)
+	s.Handle("POST", path, handles...)
+}
+
+func (s *Server) PUT(path string, handles ...HandlerFunc) {
+	s.Handle("PUT", path, handles...)
+}
+
+func (s *Server) PATCH(path string, handles ...HandlerFunc) {
+	s.Handle("PATCH", path, handles...)
+}
+
+func (s *Server) DELETE(path string, handles ...HandlerFunc) {
+	s.Handle("DELETE", path, handles...)
+}
+
+// HEAD requests fall back to the GET handlers when no HEAD handler is registered
+func (s *Server) HEAD(path string, handles ...HandlerFunc) {
+	s.Handle("HEAD", path, handles...)
+}
+
+func (s *Server) OPTIONS(path string, handles ...HandlerFunc) {
+	s.Handle("OPTIONS", path, handles...)
 }
 
+// ANY matches every http method without a dedicated handler
 func (s *Server) ANY(path string, handles ...HandlerFunc) {
-	s.anyRouter[path] = s.prepare(handles...)
+	s.Handle(methodAny, path, handles...)
 }
 
 func (s *Server) Run(addr string) error {
@@ -110,107 +239,120 @@ func (s *Server) ServeHTTP(res http.ResponseWriter, req *http.Request) {
 	}()
 
 	var ctx = newContext(req, res)
+	ctx.server = s
 	defer func() {
-		if err := recover(); err != nil {
-			s.catch(ctx, err)
+		// net/http only removes the files of the request it passed in,
+		// not of the copies made by SetContext
+		if ctx.Request.MultipartForm != nil {
+			ctx.Request.MultipartForm.RemoveAll()
 		}
 	}()
-
-	for _, fn := range s.handlers {
-		fn(ctx)
-		if !ctx.next {
-			return
+	defer func() {
+		if v := recover(); v != nil {
+			// errors thrown with Throw take the error chain, other panics go to catch
+			if t, ok := v.(*thrown); ok {
+				var name string
+				if ctx.index >= 0 && ctx.index < len(ctx.handlers) {
+					name = runtime.FuncForPC(reflect.ValueOf(ctx.handlers[ctx.index]).Pointer()).Name()
+				}
+				ctx.addError(t.err, name)
+				s.handleError(ctx, t.err)
+				return
+			}
+			s.catch(ctx, v)
 		}
-	}
+	}()
 
 	req.URL.Path = strings.TrimSpace(req.URL.Path)
-	var handlers []HandlerFunc
-	var exist bool
-
-	switch req.Method {
-	case "GET":
-		handlers, exist = s.getRouter[req.URL.Path]
-	case "POST":
-		handlers, exist = s.postRouter[req.URL.Path]
-	}
-
-	if !exist {
-		handlers, exist = s.anyRouter[req.URL.Path]
+	if n, params := s.router.find(req.URL.Path); n == nil {
+		ctx.handlers = s.noRouteChain
+	} else if chain, opt, exist := n.lookup(req.Method); exist {
+		ctx.params = params
+		ctx.handlers = chain
+		s.limitBody(ctx, opt)
+	} else {
+		res.Header().Set("Allow", strings.Join(n.allowed(), ", "))
+		ctx.handlers = s.noMethodChain
 	}
+	ctx.Next()
 
-	if !exist {
-		ctx.Write(404, []byte("handler not exist"))
+	if n := len(ctx.errors); n > 0 {
+		s.handleError(ctx, ctx.errors[n-1].err)
 	}
+}
 
-	for _, fn := range handlers {
-		fn(ctx)
-		if !ctx.next {
-			break
-		}
-	}
+type RouteInfo struct {
+	Method  string
+	Path    string
+	Handler string
+	Option  *RouteOption
 }
 
-func (s *Server) fprintRouters() {
-	var m = make([]struct {
-		Path   string
-		Method string
-		Fn     string
-	}, 0)
-	for k, fns := range s.getRouter {
+// Routes returns the registered routes sorted by path and method
+func (s *Server) Routes() []RouteInfo {
+	var m = make([]RouteInfo, 0)
+	s.router.walk(func(pattern string, method string, fns []HandlerFunc, opt *RouteOption) {
 		var n = len(fns)
 		if n == 0 {
-			continue
+			return
 		}
 
-		fn := runtime.FuncForPC(reflect.ValueOf(fns[n-1]).Pointer()).Name()
-		m = append(m, struct {
-			Path   string
-			Method string
-			Fn     string
-		}{Path: k, Method: "GET", Fn: fn})
-	}
-	for k, fns := range s.postRouter {
-		var n = len(fns)
-		if n == 0 {
-			continue
+		var fn = runtime.FuncForPC(reflect.ValueOf(fns[n-1]).Pointer()).Name()
+		if opt != nil && opt.handler != "" {
+			fn = opt.handler
 		}
-
-		fn := runtime.FuncForPC(reflect.ValueOf(fns[n-1]).Pointer()).Name()
-		m = append(m, struct {
-			Path   string
-			Method string
-			Fn     string
-		}{Path: k, Method: "POST", Fn: fn})
-	}
-	for k, fns := range s.anyRouter {
-		var n = len(fns)
-		if n == 0 {
-			continue
-		}
-
-		fn := runtime.FuncForPC(reflect.ValueOf(fns[n-1]).Pointer()).Name()
-		m = append(m, struct {
-			Path   string
-			Method string
-			Fn     string
-		}{Path: k, Method: "GET", Fn: fn}, struct {
-			Path   string
-			Method string
-			Fn     string
-		}{Path: k, Method: "POST", Fn: fn})
-	}
+		m = append(m, RouteInfo{Method: method, Path: pattern, Handler: fn, Option: opt})
+	})
 
 	var length = len(m)
 	for i := 0; i < length-1; i++ {
 		for j := i + 1; j < length; j++ {
-			if m[i].Path > m[j].Path {
+			if m[i].Path > m[j].Path || (m[i].Path == m[j].Path && m[i].Method > m[j].Method) {
 				m[i], m[j] = m[j], m[i]
 			}
 		}
 	}
+	return m
+}
 
+// limitBody applies the body size limit of the route, unless it reads the body as a stream
+func (s *Server) limitBody(ctx *Context, opt *RouteOption) {
+	var limit = s.maxBodyBytes
+	if opt != nil {
+		if opt.Stream {
+			ctx.stream = true
+			return
+		}
+		if opt.MaxBodyBytes != 0 {
+			limit = opt.MaxBodyBytes
+		}
+	}
+	if limit > 0 && ctx.Request.Body != nil {
+		ctx.Request.Body = &limitedBody{ReadCloser: http.MaxBytesReader(ctx.Response, ctx.Request.Body, limit), limit: limit}
+	}
+}
+
+// limitedBody replaces the error of http.MaxBytesReader with ErrBodyTooLarge,
+// counting the bytes read to tell the limit from other read errors
+type limitedBody struct {
+	io.ReadCloser
+	read  int64
+	limit int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		err = ErrBodyTooLarge
+	}
+	return n, err
+}
+
+func (s *Server) fprintRouters() {
+	var m = s.Routes()
 	var maxLength = 0
-	for i := 0; i < length; i++ {
+	for i := 0; i < len(m); i++ {
 		if len(m[i].Path) > maxLength {
 			maxLength = len(m[i].Path)
 		}
@@ -218,9 +360,9 @@ func (s *Server) fprintRouters() {
 	for _, item := range m {
 		fmt.Printf(
 			"%s %s -> %s\n",
-			appendSpace("["+item.Method+"]", 6),
+			appendSpace("["+item.Method+"]", 9),
 			appendSpace(item.Path, maxLength),
-			item.Fn,
+			item.Handler,
 		)
 	}
 }
@@ -0,0 +1,405 @@
+package fastapi
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// request serves a request on s, header holds key, value pairs
+func request(s *Server, method string, target string, body io.Reader, header ...string) *httptest.ResponseRecorder {
+	var req = httptest.NewRequest(method, target, body)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	var w = httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+// reply returns a handler writing text
+func reply(text string) HandlerFunc {
+	return func(ctx *Context) {
+		ctx.Write(200, []byte(text))
+	}
+}
+
+func TestServerMethods(t *testing.T) {
+	var s = New()
+	s.GET("/r", reply("GET"))
+	s.POST("/r", reply("POST"))
+	s.PUT("/r", reply("PUT"))
+	s.PATCH("/r", reply("PATCH"))
+	s.DELETE("/r", reply("DELETE"))
+	s.OPTIONS("/r", reply("OPTIONS"))
+	s.Handle("purge", "/r", reply("PURGE"))
+
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "PURGE"} {
+		w := request(s, method, "/r", nil)
+		if w.Code != 200 || w.Body.String() != method {
+			t.Errorf("%s: %d %q", method, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestServerAny(t *testing.T) {
+	var s = New()
+	s.ANY("/r", reply("ANY"))
+	s.GET("/r", reply("GET"))
+
+	if w := request(s, "GET", "/r", nil); w.Body.String() != "GET" {
+		t.Errorf("GET answered %q", w.Body.String())
+	}
+	if w := request(s, "DELETE", "/r", nil); w.Body.String() != "ANY" {
+		t.Errorf("DELETE answered %q", w.Body.String())
+	}
+}
+
+func TestGroupMethods(t *testing.T) {
+	var s = New()
+	var g = s.Group("/api").Group("/v1")
+	g.GET("/r", reply("GET"))
+	g.POST("/r", reply("POST"))
+	g.PUT("/r", reply("PUT"))
+	g.PATCH("/r", reply("PATCH"))
+	g.DELETE("/r", reply("DELETE"))
+	g.HEAD("/r", reply("HEAD"))
+	g.OPTIONS("/r", reply("OPTIONS"))
+	g.ANY("/any", reply("ANY"))
+
+	for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"} {
+		w := request(s, method, "/api/v1/r", nil)
+		if w.Code != 200 || w.Body.String() != method {
+			t.Errorf("%s: %d %q", method, w.Code, w.Body.String())
+		}
+	}
+	if w := request(s, "PATCH", "/api/v1/any", nil); w.Body.String() != "ANY" {
+		t.Errorf("ANY answered %q", w.Body.String())
+	}
+}
+
+func TestServerNoRoute(t *testing.T) {
+	var s = New()
+	s.GET("/r", reply("GET"))
+
+	if w := request(s, "GET", "/none", nil); w.Code != 404 || w.Body.String() != `{"code":5,"msg":"handler not exist"}` {
+		t.Errorf("no route: %d %s", w.Code, w.Body.String())
+	}
+
+	s.Use(func(ctx *Context) {
+		ctx.Response.Header().Set("X-Global", "1")
+	})
+	s.NoRoute(reply("custom"))
+	w := request(s, "GET", "/none", nil)
+	if w.Code != 200 || w.Body.String() != "custom" || w.Header().Get("X-Global") != "1" {
+		t.Errorf("custom no route: %d %s %v", w.Code, w.Body.String(), w.Header())
+	}
+}
+
+func TestServerNoMethod(t *testing.T) {
+	var s = New()
+	s.GET("/r", reply("GET"))
+	s.POST("/r", reply("POST"))
+
+	w := request(s, "DELETE", "/r", nil)
+	if w.Code != 405 || w.Body.String() != `{"code":12,"msg":"method not allowed"}` {
+		t.Errorf("no method: %d %s", w.Code, w.Body.String())
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, POST" {
+		t.Errorf("Allow = %q", allow)
+	}
+
+	s.NoMethod(func(ctx *Context) {
+		ctx.Write(405, []byte(ctx.Response.Header().Get("Allow")))
+	})
+	if w := request(s, "PUT", "/r", nil); w.Code != 405 || w.Body.String() != "GET, HEAD, POST" {
+		t.Errorf("custom no method: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServerHeadFallback(t *testing.T) {
+	var s = New()
+	s.GET("/get", reply("GET"))
+	s.GET("/both", reply("GET"))
+	s.HEAD("/both", reply("HEAD"))
+
+	if w := request(s, "HEAD", "/get", nil); w.Code != 200 || w.Body.String() != "GET" {
+		t.Errorf("HEAD /get: %d %q", w.Code, w.Body.String())
+	}
+	if w := request(s, "HEAD", "/both", nil); w.Body.String() != "HEAD" {
+		t.Errorf("HEAD /both: %q", w.Body.String())
+	}
+}
+
+func TestServerChainOrder(t *testing.T) {
+	var s = New()
+	var trace []string
+	var mark = func(name string) HandlerFunc {
+		return func(ctx *Context) {
+			trace = append(trace, name)
+		}
+	}
+	s.GET("/r", mark("route"))
+	var g = s.Group("/g", mark("group"))
+	g.GET("/r", mark("route"))
+
+	// global middleware registered after the routes still runs first
+	s.Use(mark("global"))
+
+	request(s, "GET", "/r", nil)
+	request(s, "GET", "/g/r", nil)
+	if got := strings.Join(trace, ","); got != "global,route,global,group,route" {
+		t.Errorf("trace %s", got)
+	}
+}
+
+func TestServerMaxBodyBytes(t *testing.T) {
+	var s = New()
+	s.SetMaxBodyBytes(8)
+	var echo = func(ctx *Context) {
+		ctx.Write(200, ctx.GetBody())
+	}
+	var bind = HandlerE(func(ctx *Context) error {
+		var form bindForm
+		if err := ctx.Bind(&form); err != nil {
+			return err
+		}
+		return ctx.JSON(200, &form)
+	})
+	s.POST("/echo", echo)
+	s.POST("/bind", bind)
+	s.HandleWith("POST", "/large", &RouteOption{MaxBodyBytes: 64}, echo)
+	s.HandleWith("POST", "/unlimited", &RouteOption{MaxBodyBytes: -1}, echo)
+
+	const tooLarge = `{"code":8,"msg":"request body too large"}`
+	var large = strings.Repeat("x", 16)
+	if w := request(s, "POST", "/echo", strings.NewReader("small"), "Content-Type", "text/plain"); w.Code != 200 || w.Body.String() != "small" {
+		t.Errorf("small body: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "POST", "/echo", strings.NewReader(large), "Content-Type", "text/plain"); w.Code != 413 || w.Body.String() != tooLarge {
+		t.Errorf("large body: %d %s", w.Code, w.Body.String())
+	}
+	for _, item := range [][2]string{
+		{ContentType.JSON, `{"name":"xxxxxxxxxxxx"}`},
+		{ContentType.Form, "name=xxxxxxxxxxxx"},
+	} {
+		if w := request(s, "POST", "/bind", strings.NewReader(item[1]), "Content-Type", item[0]); w.Code != 413 || w.Body.String() != tooLarge {
+			t.Errorf("bind %s: %d %s", item[0], w.Code, w.Body.String())
+		}
+	}
+	body, contentType := multipartBody(t, map[string]string{"name": large})
+	if w := request(s, "POST", "/bind", body, "Content-Type", contentType); w.Code != 413 {
+		t.Errorf("bind multipart: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := request(s, "POST", "/large", strings.NewReader(large), "Content-Type", "text/plain"); w.Code != 200 || w.Body.String() != large {
+		t.Errorf("route limit: %d %s", w.Code, w.Body.String())
+	}
+	large = strings.Repeat("x", 128)
+	if w := request(s, "POST", "/large", strings.NewReader(large), "Content-Type", "text/plain"); w.Code != 413 {
+		t.Errorf("route limit: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "POST", "/unlimited", strings.NewReader(large), "Content-Type", "text/plain"); w.Code != 200 || w.Body.String() != large {
+		t.Errorf("no limit: %d", w.Code)
+	}
+}
+
+func TestServerStream(t *testing.T) {
+	var s = New()
+	s.SetMaxBodyBytes(8)
+	var opt = &RouteOption{Stream: true}
+	s.HandleWith("POST", "/stream", opt, func(ctx *Context) {
+		body, err := ioutil.ReadAll(ctx.Request.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx.Write(200, body)
+	})
+	s.HandleWith("POST", "/buffered", opt, func(ctx *Context) {
+		ctx.GetBody()
+	})
+
+	var large = strings.Repeat("x", 16)
+	if w := request(s, "POST", "/stream", strings.NewReader(large), "Content-Type", "text/plain"); w.Code != 200 || w.Body.String() != large {
+		t.Errorf("stream: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "POST", "/buffered", strings.NewReader(large), "Content-Type", "text/plain"); w.Code != 500 || !strings.Contains(w.Body.String(), "stream") {
+		t.Errorf("GetBody on a stream: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServerSetHTTPStatus(t *testing.T) {
+	var s = New()
+	s.SetMaxBodyBytes(1)
+	s.GET("/denied", HandlerE(func(ctx *Context) error {
+		return NewError(PermissionDenied, "denied")
+	}))
+	s.POST("/body", func(ctx *Context) {
+		ctx.GetBody()
+	})
+
+	if w := request(s, "GET", "/denied", nil); w.Code != 403 {
+		t.Errorf("default mapping: %d", w.Code)
+	}
+	s.SetHTTPStatus(func(code Code) int {
+		return 200
+	})
+	if w := request(s, "GET", "/denied", nil); w.Code != 200 || w.Body.String() != `{"code":7,"msg":"denied"}` {
+		t.Errorf("custom mapping: %d %s", w.Code, w.Body.String())
+	}
+	// errors with their own status keep it
+	if w := request(s, "POST", "/body", strings.NewReader("large"), "Content-Type", "text/plain"); w.Code != 413 {
+		t.Errorf("body too large: %d", w.Code)
+	}
+}
+
+func TestServerSetCodeEncoding(t *testing.T) {
+	var s = New()
+	s.SetCodeEncoding(CodeSymbolic)
+	if w := request(s, "GET", "/none", nil); w.Code != 404 || w.Body.String() != `{"code":"NOT_FOUND","msg":"handler not exist"}` {
+		t.Errorf("%d %s", w.Code, w.Body.String())
+	}
+}
+
+func throwNotFound(ctx *Context) {
+	Throw(NewError(NotFound, "user not found"))
+}
+
+func deny(ctx *Context) error {
+	return NewError(PermissionDenied, "denied")
+}
+
+func reportAfterNext(ctx *Context) {
+	ctx.Next()
+	ctx.Error(NewError(Unavailable, "audit failed"))
+}
+
+func TestServerErrorChain(t *testing.T) {
+	var s = New()
+	var seen []string
+	s.OnError(func(ctx *Context, err error) error {
+		for _, item := range ctx.errors {
+			seen = append(seen, item.handler[strings.LastIndex(item.handler, ".")+1:]+":"+item.err.Error())
+		}
+		return err
+	}, func(ctx *Context, err error) error {
+		if e, ok := err.(*Error); ok && e.Code == Unavailable && !ctx.Written() {
+			ctx.Write(503, []byte("mapped"))
+			return nil
+		}
+		return err
+	})
+	s.GET("/throw", throwNotFound)
+	s.GET("/report", reportAfterNext, reply("ok"))
+	s.GET("/unavailable", reportAfterNext)
+	s.GET("/handlere", HandlerE(deny), reply("unreachable"))
+
+	if w := request(s, "GET", "/throw", nil); w.Code != 404 || w.Body.String() != `{"code":5,"msg":"user not found"}` {
+		t.Errorf("throw: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/report", nil); w.Code != 200 || w.Body.String() != "ok" {
+		t.Errorf("report: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/unavailable", nil); w.Code != 503 || w.Body.String() != "mapped" {
+		t.Errorf("unavailable: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/handlere", nil); w.Code != 403 || w.Body.String() != `{"code":7,"msg":"denied"}` {
+		t.Errorf("HandlerE: %d %s", w.Code, w.Body.String())
+	}
+	if got := strings.Join(seen, ","); got != "throwNotFound:user not found,reportAfterNext:audit failed,reportAfterNext:audit failed,deny:denied" {
+		t.Errorf("handlers %s", got)
+	}
+}
+
+func TestServerErrorAfterWrite(t *testing.T) {
+	var s = New()
+	var chained = 0
+	s.OnError(func(ctx *Context, err error) error {
+		chained++
+		return err
+	})
+	s.GET("/late", func(ctx *Context) {
+		ctx.Next()
+		ctx.Error(NewError(NotFound, "late"))
+	}, reply("ok"))
+	s.GET("/panic", func(ctx *Context) {
+		ctx.Write(200, []byte("ok"))
+		panic(io.ErrUnexpectedEOF)
+	})
+
+	for _, path := range []string{"/late", "/panic"} {
+		if w := request(s, "GET", path, nil); w.Code != 200 || w.Body.String() != "ok" {
+			t.Errorf("%s: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+	if chained != 1 {
+		t.Errorf("the error chain ran %d times", chained)
+	}
+}
+
+func TestServerWrappedErrors(t *testing.T) {
+	var s = New()
+	s.GET("/wrap", HandlerE(func(ctx *Context) error {
+		return errors.Wrap(NewError(NotFound, "user not found"), "load")
+	}))
+	s.GET("/errorf", HandlerE(func(ctx *Context) error {
+		return fmt.Errorf("check: %w", NewError(PermissionDenied, "denied"))
+	}))
+	s.GET("/validation", HandlerE(func(ctx *Context) error {
+		var form struct {
+			Name string `json:"name" validate:"required"`
+		}
+		return errors.WithMessage(ctx.Bind(&form), "bind")
+	}))
+
+	if w := request(s, "GET", "/wrap", nil); w.Code != 404 || w.Body.String() != `{"code":5,"msg":"user not found"}` {
+		t.Errorf("Wrap: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/errorf", nil); w.Code != 403 || w.Body.String() != `{"code":7,"msg":"denied"}` {
+		t.Errorf("Errorf: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/validation", nil); w.Code != 400 || !strings.Contains(w.Body.String(), `"code":3`) {
+		t.Errorf("TransError: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServerPanics(t *testing.T) {
+	SetMode(ProductMode)
+	defer SetMode(DebugMode)
+
+	var s = New()
+	var chained = 0
+	s.OnError(func(ctx *Context, err error) error {
+		chained++
+		return err
+	})
+	s.GET("/error", func(ctx *Context) {
+		panic(io.ErrUnexpectedEOF)
+	})
+	s.GET("/runtime", func(ctx *Context) {
+		var m map[string]int
+		m["x"] = 1
+	})
+
+	for _, path := range []string{"/error", "/runtime"} {
+		if w := request(s, "GET", path, nil); w.Code != 500 || w.Body.String() != `{"code":13,"msg":"internal error"}` {
+			t.Errorf("%s: %d %s", path, w.Code, w.Body.String())
+		}
+	}
+	if chained != 0 {
+		t.Errorf("panics ran the error chain %d times", chained)
+	}
+
+	var caught interface{}
+	s.SetCatch(func(ctx *Context, err interface{}) {
+		caught = err
+	})
+	request(s, "GET", "/error", nil)
+	if caught != io.ErrUnexpectedEOF {
+		t.Errorf("catch got %v", caught)
+	}
+}
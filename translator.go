@@ -1,12 +1,31 @@
 package fastapi
 
 import (
+	"fmt"
+	"github.com/go-playground/locales"
 	en "github.com/go-playground/locales/en_US"
+	es "github.com/go-playground/locales/es"
+	fr "github.com/go-playground/locales/fr"
+	ja "github.com/go-playground/locales/ja"
+	pt "github.com/go-playground/locales/pt_BR"
+	ru "github.com/go-playground/locales/ru"
 	cn "github.com/go-playground/locales/zh_Hans_CN"
+	tw "github.com/go-playground/locales/zh_Hant_TW"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	ja_translations "github.com/go-playground/validator/v10/translations/ja"
+	pt_translations "github.com/go-playground/validator/v10/translations/pt_BR"
+	ru_translations "github.com/go-playground/validator/v10/translations/ru"
 	cn_translations "github.com/go-playground/validator/v10/translations/zh"
+	tw_translations "github.com/go-playground/validator/v10/translations/zh_tw"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 )
 
 type Lang uint8
@@ -14,35 +33,227 @@ type Lang uint8
 const (
 	Chinese Lang = iota
 	English
+	Japanese
+	Spanish
+	French
+	Portuguese
+	Russian
+	TraditionalChinese
 )
 
+// language is a locale loaded into uni, with its validator translations registered
+type language struct {
+	tag   string
+	trans ut.Translator
+}
+
 var (
-	trans    ut.Translator
-	uni      *ut.UniversalTranslator
-	validate *validator.Validate
+	uni       *ut.UniversalTranslator
+	validate  *validator.Validate
+	languages = map[Lang]*language{}
+	langTags  = map[string]Lang{}
+	nextLang  = TraditionalChinese + 1
+
+	// defaultLang is read by every request, SetLang stores it atomically
+	defaultLang uint32
+
+	langQuery  string
+	langCookie string
+
+	// translations keeps the messages of RegisterTranslation, for languages registered later
+	translations = map[string]map[Lang]string{}
 )
 
+func init() {
+	uni = ut.New(cn.New())
+	validate = newValidate()
+	addLang(Chinese, "zh", cn.New(), cn_translations.RegisterDefaultTranslations)
+	addLang(English, "en", en.New(), en_translations.RegisterDefaultTranslations)
+	addLang(Japanese, "ja", ja.New(), ja_translations.RegisterDefaultTranslations)
+	addLang(Spanish, "es", es.New(), es_translations.RegisterDefaultTranslations)
+	addLang(French, "fr", fr.New(), fr_translations.RegisterDefaultTranslations)
+	addLang(Portuguese, "pt", pt.New(), pt_translations.RegisterDefaultTranslations)
+	addLang(Russian, "ru", ru.New(), ru_translations.RegisterDefaultTranslations)
+	addLang(TraditionalChinese, "zh-tw", tw.New(), tw_translations.RegisterDefaultTranslations)
+	langTags["zh-hant"] = TraditionalChinese
+	langTags["zh-hk"] = TraditionalChinese
+}
+
+func addLang(lang Lang, tag string, locale locales.Translator, register func(v *validator.Validate, trans ut.Translator) error) {
+	if err := uni.AddTranslator(locale, true); err != nil {
+		panic(err)
+	}
+	trans, _ := uni.GetTranslator(locale.Locale())
+	if err := register(validate, trans); err != nil {
+		panic(err)
+	}
+	languages[lang] = &language{tag: tag, trans: trans}
+	langTags[strings.ToLower(tag)] = lang
+	for rule := range translations {
+		if err := addTranslation(rule, lang); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterLang loads a language the package doesn't ship and returns its Lang.
+// tag is matched against Accept-Language and the SetLangKey sources, e.g. de or pt-PT,
+// and register adds the validator messages, e.g. a translations RegisterDefaultTranslations.
+// Call it before serving, it is not safe for concurrent use with requests.
+func RegisterLang(tag string, locale locales.Translator, register func(v *validator.Validate, trans ut.Translator) error) Lang {
+	if _, ok := langTags[strings.ToLower(tag)]; ok {
+		panic(fmt.Sprintf("fastapi: language %s is already registered", tag))
+	}
+	var lang = nextLang
+	nextLang++
+	addLang(lang, tag, locale, register)
+	return lang
+}
+
+// String returns the language tag, e.g. zh, en or zh-tw
+func (l Lang) String() string {
+	if item, ok := languages[l]; ok {
+		return item.tag
+	}
+	return "Lang(" + strconv.Itoa(int(l)) + ")"
+}
+
+// SetLang sets the language of requests that don't ask for one.
+// It is safe to call while serving.
 func SetLang(lang Lang) {
-	switch lang {
-	case Chinese:
-		setChinese()
-	case English:
-		setEnglish()
+	atomic.StoreUint32(&defaultLang, uint32(lang))
+}
+
+// SetLangKey names the query parameter and cookie that choose the language
+// of a request, e.g. ?lang=en. They take precedence over Accept-Language.
+// An empty name disables the source.
+func SetLangKey(query string, cookie string) {
+	langQuery = query
+	langCookie = cookie
+}
+
+// matchLang finds a loaded language by tag, dropping subtags until one matches:
+// zh-TW matches zh-tw, zh-Hant-HK matches zh-hant and zh-CN falls back to zh
+func matchLang(tag string) (Lang, bool) {
+	tag = strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+	for tag != "" {
+		if lang, ok := langTags[tag]; ok {
+			return lang, true
+		}
+		var i = strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
 	}
+	return 0, false
 }
 
-func setChinese() {
-	cn_translator := cn.New()
-	uni = ut.New(cn_translator, cn_translator)
-	trans, _ = uni.GetTranslator("zh")
-	validate = validator.New()
-	cn_translations.RegisterDefaultTranslations(validate, trans)
+// parseAcceptLanguage returns the first loaded language of the header by quality,
+// e.g. fr-CH, en;q=0.8, zh;q=0.9 prefers zh
+func parseAcceptLanguage(header string) (Lang, bool) {
+	type item struct {
+		tag string
+		q   float64
+	}
+	var items = make([]item, 0, 4)
+	for _, part := range strings.Split(header, ",") {
+		var fields = strings.Split(part, ";")
+		var it = item{tag: strings.TrimSpace(fields[0]), q: 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					it.q = q
+				}
+			}
+		}
+		if it.tag != "" && it.q > 0 {
+			items = append(items, it)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].q > items[j].q
+	})
+
+	for _, it := range items {
+		if lang, ok := matchLang(it.tag); ok {
+			return lang, true
+		}
+	}
+	return 0, false
+}
+
+// translator returns the validator translator of lang, or of the default language
+func translator(lang Lang) ut.Translator {
+	if item, ok := languages[lang]; ok {
+		return item.trans
+	}
+	return languages[Lang(atomic.LoadUint32(&defaultLang))].trans
+}
+
+// RegisterValidation adds the validate rule tag, e.g. validate:"phone_cn", with its
+// messages per language. See RegisterTranslation for the messages.
+// Call it before serving, like the validator it is not safe for concurrent use.
+func RegisterValidation(tag string, fn validator.Func, messages map[Lang]string) error {
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	return RegisterTranslation(tag, messages)
+}
+
+// RegisterStructValidation adds a struct level rule for the types, which reports
+// its errors with StructLevel.ReportError and a tag given messages by RegisterTranslation.
+func RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	validate.RegisterStructValidation(fn, types...)
+}
+
+// RegisterTranslation sets the messages of the rule tag, replacing the built-in ones.
+// {0} is the field name and {1} the rule parameter, e.g. "{0} must be at least {1}".
+// Languages without a message use the English one, then the Chinese one.
+func RegisterTranslation(tag string, messages map[Lang]string) error {
+	translations[tag] = messages
+	for lang := range languages {
+		if err := addTranslation(tag, lang); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addTranslation(tag string, lang Lang) error {
+	var messages = translations[tag]
+	msg, ok := messages[lang]
+	if !ok {
+		msg, ok = messages[English]
+	}
+	if !ok {
+		msg, ok = messages[Chinese]
+	}
+	if !ok {
+		return nil
+	}
+
+	return validate.RegisterTranslation(tag, languages[lang].trans, func(trans ut.Translator) error {
+		return trans.Add(tag, msg, true)
+	}, func(trans ut.Translator, fe validator.FieldError) string {
+		s, _ := trans.T(tag, fe.Field(), fe.Param())
+		return s
+	})
 }
 
-func setEnglish() {
-	en_translator := en.New()
-	uni = ut.New(en_translator, en_translator)
-	trans, _ = uni.GetTranslator("en")
-	validate = validator.New()
-	en_translations.RegisterDefaultTranslations(validate, trans)
+// newValidate reports fields by their json name, the name clients send
+func newValidate() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
 }
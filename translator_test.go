@@ -0,0 +1,221 @@
+package fastapi
+
+import (
+	"github.com/go-playground/locales/id"
+	"github.com/go-playground/validator/v10"
+	id_translations "github.com/go-playground/validator/v10/translations/id"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestMatchLang(t *testing.T) {
+	for tag, want := range map[string]Lang{
+		"en":         English,
+		"EN-us":      English,
+		"zh_CN":      Chinese,
+		"zh-TW":      TraditionalChinese,
+		"zh-Hant-HK": TraditionalChinese,
+		"zh-HK":      TraditionalChinese,
+		"pt-PT":      Portuguese,
+		"es-MX":      Spanish,
+	} {
+		if lang, ok := matchLang(tag); !ok || lang != want {
+			t.Errorf("%s: %v %v, want %v", tag, lang, ok, want)
+		}
+	}
+	for _, tag := range []string{"", "de", "x-en"} {
+		if lang, ok := matchLang(tag); ok {
+			t.Errorf("%s matched %v", tag, lang)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	for header, want := range map[string]Lang{
+		"en":                        English,
+		"fr-CH, en;q=0.8, zh;q=0.9": French,
+		"de, en;q=0.8, zh;q=0.9":    Chinese,
+		"ja;q=0.1, ru;q=0.5":        Russian,
+		"en;q=0, ja":                Japanese,
+	} {
+		if lang, ok := parseAcceptLanguage(header); !ok || lang != want {
+			t.Errorf("%s: %v %v, want %v", header, lang, ok, want)
+		}
+	}
+	for _, header := range []string{"", "de", "*", "en;q=0"} {
+		if lang, ok := parseAcceptLanguage(header); ok {
+			t.Errorf("%s matched %v", header, lang)
+		}
+	}
+}
+
+func TestContextLang(t *testing.T) {
+	SetLangKey("lang", "lang")
+	defer SetLangKey("", "")
+
+	var lang = func(target string, header ...string) Lang {
+		var req = httptest.NewRequest("GET", target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		return newContext(req, httptest.NewRecorder()).Lang()
+	}
+	if l := lang("/"); l != Chinese {
+		t.Errorf("default %v", l)
+	}
+	if l := lang("/", "Accept-Language", "en-US,en;q=0.9"); l != English {
+		t.Errorf("Accept-Language %v", l)
+	}
+	if l := lang("/", "Cookie", "lang=ja", "Accept-Language", "en"); l != Japanese {
+		t.Errorf("cookie %v", l)
+	}
+	if l := lang("/?lang=fr", "Cookie", "lang=ja", "Accept-Language", "en"); l != French {
+		t.Errorf("query %v", l)
+	}
+	if l := lang("/?lang=de", "Accept-Language", "en"); l != English {
+		t.Errorf("unknown query %v", l)
+	}
+
+	SetLang(English)
+	defer SetLang(Chinese)
+	if l := lang("/", "Accept-Language", "de"); l != English {
+		t.Errorf("SetLang %v", l)
+	}
+}
+
+func TestBindLang(t *testing.T) {
+	var form struct {
+		Name string `json:"name" validate:"required"`
+	}
+	for header, want := range map[string]string{
+		"en": "name is a required field",
+		"zh": "name为必填字段",
+		"es": "name es un campo requerido",
+	} {
+		var req = httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", header)
+		var err = newContext(req, httptest.NewRecorder()).Bind(&form)
+		if err == nil || err.Error() != want {
+			t.Errorf("%s: %v, want %s", header, err, want)
+		}
+	}
+}
+
+// languages can not be unregistered, so -count=N registers "id" only once
+var (
+	registerIndonesian sync.Once
+	indonesian         Lang
+)
+
+func TestRegisterLang(t *testing.T) {
+	registerIndonesian.Do(func() {
+		indonesian = RegisterLang("id", id.New(), id_translations.RegisterDefaultTranslations)
+	})
+	if indonesian.String() != "id" || Spanish.String() != "es" || Lang(200).String() != "Lang(200)" {
+		t.Errorf("String %s %s %s", indonesian, Spanish, Lang(200))
+	}
+
+	var form struct {
+		Name string `json:"name" validate:"required"`
+	}
+	var req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "id-ID")
+	if err := newContext(req, httptest.NewRecorder()).Bind(&form); err == nil || err.Error() != "name wajib diisi" {
+		t.Errorf("Bind: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a language twice does not panic")
+		}
+	}()
+	RegisterLang("EN", id.New(), id_translations.RegisterDefaultTranslations)
+}
+
+// bindLang binds v from a GET request to target in lang
+func bindLang(v interface{}, target string, lang string) error {
+	var req = httptest.NewRequest("GET", target, nil)
+	req.Header.Set("Accept-Language", lang)
+	return newContext(req, httptest.NewRecorder()).Bind(v)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	var err = RegisterValidation("test_even", func(fl validator.FieldLevel) bool {
+		return fl.Field().Int()%2 == 0
+	}, map[Lang]string{
+		English: "{0} must be even",
+		Chinese: "{0}必须是偶数",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type form struct {
+		Count int `json:"count" validate:"test_even"`
+	}
+	if err := bindLang(&form{}, "/?count=2", "en"); err != nil {
+		t.Errorf("valid: %v", err)
+	}
+	for lang, want := range map[string]string{
+		"en": "count must be even",
+		"zh": "count必须是偶数",
+		"fr": "count must be even",
+	} {
+		if err := bindLang(&form{}, "/?count=3", lang); err == nil || err.Error() != want {
+			t.Errorf("%s: %v, want %s", lang, err, want)
+		}
+	}
+
+	if err := RegisterValidation("", nil, nil); err == nil {
+		t.Error("an empty tag is accepted")
+	}
+}
+
+type passwordForm struct {
+	Password string `json:"password"`
+	Confirm  string `json:"confirm"`
+}
+
+func TestRegisterStructValidation(t *testing.T) {
+	RegisterStructValidation(func(sl validator.StructLevel) {
+		var form = sl.Current().Interface().(passwordForm)
+		if form.Password != form.Confirm {
+			sl.ReportError(form.Confirm, "confirm", "Confirm", "test_eqpassword", "")
+		}
+	}, passwordForm{})
+	if err := RegisterTranslation("test_eqpassword", map[Lang]string{Chinese: "{0}与密码不一致"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bindLang(&passwordForm{}, "/?password=a&confirm=a", "en"); err != nil {
+		t.Errorf("valid: %v", err)
+	}
+	var err = bindLang(&passwordForm{}, "/?password=a&confirm=b", "en")
+	if e, ok := err.(*TransError); !ok || e.Field != "confirm" || e.Message != "confirm与密码不一致" {
+		t.Errorf("Bind: %v", err)
+	}
+}
+
+func TestRegisterTranslation(t *testing.T) {
+	type form struct {
+		Name string `json:"name" validate:"test_required"`
+	}
+	if err := RegisterValidation("test_required", func(fl validator.FieldLevel) bool {
+		return fl.Field().String() != ""
+	}, map[Lang]string{English: "{0} is missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterTranslation("test_required", map[Lang]string{English: "{0} is needed", Japanese: "{0}が必要です"}); err != nil {
+		t.Fatal(err)
+	}
+	for lang, want := range map[string]string{
+		"en": "name is needed",
+		"ja": "nameが必要です",
+		"ru": "name is needed",
+	} {
+		if err := bindLang(&form{}, "/", lang); err == nil || err.Error() != want {
+			t.Errorf("%s: %v, want %s", lang, err, want)
+		}
+	}
+}
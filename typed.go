@@ -0,0 +1,96 @@
+package fastapi
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+var (
+	contextType = reflect.TypeOf(&Context{})
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+type typedHandler struct {
+	fn       reflect.Value
+	name     string
+	request  reflect.Type
+	response reflect.Type
+}
+
+func newTypedHandler(fn interface{}) *typedHandler {
+	var v = reflect.ValueOf(fn)
+	var t = v.Type()
+	if t.Kind() != reflect.Func ||
+		t.NumIn() != 2 || t.In(0) != contextType ||
+		t.In(1).Kind() != reflect.Ptr || t.In(1).Elem().Kind() != reflect.Struct ||
+		t.NumOut() != 2 || t.Out(0).Kind() != reflect.Ptr || t.Out(1) != errorType {
+		panic(fmt.Sprintf("fastapi: %s is not a func(*Context, *Req) (*Resp, error)", t.String()))
+	}
+
+	return &typedHandler{
+		fn:       v,
+		name:     runtime.FuncForPC(v.Pointer()).Name(),
+		request:  t.In(1).Elem(),
+		response: t.Out(0).Elem(),
+	}
+}
+
+func (h *typedHandler) handle(ctx *Context) {
+	var req = reflect.New(h.request)
+	if err := ctx.Bind(req.Interface()); err != nil {
+		switch err.(type) {
+		case *Error, *TransError, ValidationErrors:
+			ctx.addError(err, h.name)
+		default:
+			// decoder messages describe internals, the client gets a generic one
+			ctx.addError(NewError(InvalidArgument, "invalid request body").WithCause(err), h.name)
+		}
+		ctx.Abort()
+		return
+	}
+
+	var out = h.fn.Call([]reflect.Value{reflect.ValueOf(ctx), req})
+	if err, _ := out[1].Interface().(error); err != nil {
+		ctx.addError(err, h.name)
+		ctx.Abort()
+		return
+	}
+	ctx.JSON(200, out[0].Interface())
+}
+
+// option copies opt and fills in the declared request and response types
+func (h *typedHandler) option(opt *RouteOption) *RouteOption {
+	var o = RouteOption{}
+	if opt != nil {
+		o = *opt
+	}
+	if o.Request == nil {
+		o.Request = reflect.New(h.request).Interface()
+	}
+	if o.Response == nil {
+		o.Response = reflect.New(h.response).Interface()
+	}
+	o.handler = h.name
+	return &o
+}
+
+// Typed adapts fn, a func(ctx *Context, req *Req) (*Resp, error), to a HandlerFunc.
+// The request is bound and validated with Bind, the response is written with JSON,
+// and errors are reported with ctx.Error. Typed panics if fn has another signature.
+func Typed(fn interface{}) HandlerFunc {
+	return newTypedHandler(fn).handle
+}
+
+// HandleTyped registers fn like Typed, after the handles.
+// The request and response types are recorded in the route option for Routes and OpenAPI.
+func (s *Server) HandleTyped(method string, path string, opt *RouteOption, fn interface{}, handles ...HandlerFunc) {
+	var h = newTypedHandler(fn)
+	s.HandleWith(method, path, h.option(opt), append(s.prepare(handles...), h.handle)...)
+}
+
+func (g *Group) HandleTyped(method string, path string, opt *RouteOption, fn interface{}, handlers ...HandlerFunc) {
+	var h = newTypedHandler(fn)
+	var p, hs = g.prepare(path, handlers...)
+	g.server.HandleWith(method, p, h.option(opt), append(hs, h.handle)...)
+}
@@ -0,0 +1,107 @@
+package fastapi
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type typedRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type typedResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+func greet(ctx *Context, req *typedRequest) (*typedResponse, error) {
+	if req.Name == "error" {
+		return nil, NewError(PermissionDenied, "denied")
+	}
+	return &typedResponse{Greeting: "hello " + req.Name}, nil
+}
+
+func TestTyped(t *testing.T) {
+	var s = New()
+	s.GET("/greet", Typed(greet))
+
+	if w := request(s, "GET", "/greet?name=x", nil); w.Code != 200 || w.Body.String() != `{"greeting":"hello x"}` {
+		t.Errorf("greet: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/greet", nil); w.Code != 400 || !strings.HasPrefix(w.Body.String(), `{"code":3,`) {
+		t.Errorf("invalid request: %d %s", w.Code, w.Body.String())
+	}
+	if w := request(s, "GET", "/greet?name=error", nil); w.Code != 403 || w.Body.String() != `{"code":7,"msg":"denied"}` {
+		t.Errorf("handler error: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleTyped(t *testing.T) {
+	var s = New()
+	var trace []string
+	var mark = func(ctx *Context) {
+		trace = append(trace, "middleware")
+	}
+	s.HandleTyped("POST", "/greet", &RouteOption{Summary: "greet"}, greet, mark)
+	s.Group("/v1", mark).HandleTyped("POST", "/greet", nil, greet)
+
+	for _, path := range []string{"/greet", "/v1/greet"} {
+		trace = nil
+		w := request(s, "POST", path, strings.NewReader(`{"name":"y"}`), "Content-Type", ContentType.JSON)
+		if w.Code != 200 || w.Body.String() != `{"greeting":"hello y"}` || len(trace) != 1 {
+			t.Errorf("%s: %d %s %v", path, w.Code, w.Body.String(), trace)
+		}
+	}
+
+	for _, item := range s.Routes() {
+		if !strings.HasSuffix(item.Handler, ".greet") {
+			t.Errorf("%s handler %s", item.Path, item.Handler)
+		}
+		if _, ok := item.Option.Request.(*typedRequest); !ok {
+			t.Errorf("%s request %T", item.Path, item.Option.Request)
+		}
+		if _, ok := item.Option.Response.(*typedResponse); !ok {
+			t.Errorf("%s response %T", item.Path, item.Option.Response)
+		}
+	}
+	if doc := s.OpenAPI(nil); doc.Paths["/greet"]["post"].Summary != "greet" {
+		t.Error("the route option is lost")
+	}
+}
+
+func TestTypedSignature(t *testing.T) {
+	var bad = []interface{}{
+		func(ctx *Context) {},
+		func(ctx *Context, req typedRequest) (*typedResponse, error) { return nil, nil },
+		func(ctx *Context, req *typedRequest) (typedResponse, error) { return typedResponse{}, nil },
+		func(ctx *Context, req *typedRequest) *typedResponse { return nil },
+	}
+	for i, fn := range bad {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("signature %d: want a panic", i)
+				}
+			}()
+			Typed(fn)
+		}()
+	}
+}
+
+func TestTypedInvalidBody(t *testing.T) {
+	var s = New()
+	var cause error
+	s.OnError(func(ctx *Context, err error) error {
+		cause = errors.Unwrap(err)
+		return err
+	})
+	s.POST("/greet", Typed(greet))
+
+	w := request(s, "POST", "/greet", strings.NewReader(`{"name":1}`), "Content-Type", ContentType.JSON)
+	if w.Code != 400 || w.Body.String() != `{"code":3,"msg":"invalid request body"}` {
+		t.Errorf("%d %s", w.Code, w.Body.String())
+	}
+	if cause == nil || !strings.Contains(cause.Error(), "name") {
+		t.Errorf("cause %v", cause)
+	}
+}
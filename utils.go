@@ -1,6 +1,16 @@
 package fastapi
 
-import "strconv"
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
 
 func ToInt(s string) int64 {
 	num, err := strconv.Atoi(s)
@@ -17,3 +27,23 @@ func appendSpace(s string, n int) string {
 	}
 	return s
 }
+
+// isScalar reports whether values of typ are converted from a single string:
+// bool, numbers, string, time.Duration and encoding.TextUnmarshaler types
+// such as time.Time, or a pointer to one of them.
+func isScalar(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
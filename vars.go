@@ -6,8 +6,9 @@ import (
 )
 
 var (
-	globalMode Runmode
-	accessMap  *AccessMap
+	globalMode      Runmode
+	accessMap       *AccessMap
+	multipartMemory int64 = 32 << 20
 )
 
 func init() {
@@ -25,14 +26,26 @@ func GetMode() Runmode {
 	return globalMode
 }
 
+// SetMultipartMemory sets how many bytes of a multipart/form-data body are kept
+// in memory, the rest of the files are stored on disk. Defaults to 32MB.
+func SetMultipartMemory(n int64) {
+	multipartMemory = n
+}
+
 var ContentType = struct {
-	Text string
-	JSON string
-	Form string
+	Text      string
+	JSON      string
+	Form      string
+	Multipart string
+	YAML      string
+	Problem   string
 }{
-	Text: "text/plain",
-	JSON: "application/json",
-	Form: "application/x-www-form-urlencoded",
+	Text:      "text/plain",
+	JSON:      "application/json",
+	Form:      "application/x-www-form-urlencoded",
+	Multipart: "multipart/form-data",
+	YAML:      "application/yaml",
+	Problem:   "application/problem+json",
 }
 
 func newAccessMap() *AccessMap {